@@ -0,0 +1,119 @@
+package gordon
+
+import (
+	"errors"
+	"time"
+
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var ErrRunTimedOut = errors.New("process timed out; container stopped")
+
+type CaptureOptions struct {
+	ResourceLimits       ResourceLimits
+	EnvironmentVariables []EnvironmentVariable
+
+	// MaxOutputBytes caps how much of stdout and stderr, each, is kept; the
+	// rest is read and discarded. Zero keeps everything.
+	MaxOutputBytes int
+
+	// Timeout, if set, bounds how long the process may run. On expiry the
+	// container is stopped with kill, as warden can't stop one process.
+	Timeout time.Duration
+}
+
+type CaptureResult struct {
+	Stdout     string
+	Stderr     string
+	Truncated  bool
+	ExitStatus uint32
+	Duration   time.Duration
+}
+
+// RunAndCapture runs a script to completion and collects its output. A
+// non-zero exit status is returned as an ExitStatusError along with the
+// result.
+func RunAndCapture(client Client, handle, script string, opts CaptureOptions) (*CaptureResult, error) {
+	started := time.Now()
+
+	stream, err := client.RunStream(handle, script, opts.ResourceLimits, opts.EnvironmentVariables)
+	if err != nil {
+		return nil, err
+	}
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	stdout := &capture{max: opts.MaxOutputBytes}
+	stderr := &capture{max: opts.MaxOutputBytes}
+
+	result := func() *CaptureResult {
+		return &CaptureResult{
+			Stdout:    string(stdout.data),
+			Stderr:    string(stderr.data),
+			Truncated: stdout.truncated || stderr.truncated,
+			Duration:  time.Since(started),
+		}
+	}
+
+	for {
+		select {
+		case payload, ok := <-stream.Payloads:
+			if !ok {
+				return result(), ErrNoExitStatus
+			}
+
+			if payload.ExitStatus != nil {
+				res := result()
+				res.ExitStatus = payload.GetExitStatus()
+
+				if res.ExitStatus != 0 {
+					return res, &ExitStatusError{ExitStatus: res.ExitStatus, Stderr: res.Stderr}
+				}
+
+				return res, nil
+			}
+
+			switch payload.GetSource() {
+			case warden.ProcessPayload_stdout:
+				stdout.write(payload.GetData())
+			case warden.ProcessPayload_stderr:
+				stderr.write(payload.GetData())
+			}
+
+		case <-timeout:
+			stream.Detach()
+
+			_, err := client.Stop(handle, false, true)
+			if err != nil {
+				return result(), err
+			}
+
+			return result(), ErrRunTimedOut
+		}
+	}
+}
+
+func (c *Container) RunAndCapture(script string, opts CaptureOptions) (*CaptureResult, error) {
+	return RunAndCapture(c.client, c.handle, script, opts)
+}
+
+type capture struct {
+	data      []byte
+	max       int
+	truncated bool
+}
+
+func (c *capture) write(data string) {
+	if c.max > 0 && len(c.data)+len(data) > c.max {
+		data = data[:c.max-len(c.data)]
+		c.truncated = true
+	}
+
+	c.data = append(c.data, data...)
+}
@@ -0,0 +1,167 @@
+package gordon_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("Running and capturing output", func() {
+	var fakeGordon *fake_gordon.FakeGordon
+
+	stdout := warden.ProcessPayload_stdout
+	stderr := warden.ProcessPayload_stderr
+
+	BeforeEach(func() {
+		fakeGordon = fake_gordon.New()
+	})
+
+	It("returns stdout, stderr and the exit status", func() {
+		fakeGordon.WhenRunning("some-handle", "some-script", ResourceLimits{}, nil, fake_gordon.RespondWith(
+			&warden.ProcessPayload{Source: &stdout, Data: proto.String("out-1 ")},
+			&warden.ProcessPayload{Source: &stderr, Data: proto.String("err")},
+			&warden.ProcessPayload{Source: &stdout, Data: proto.String("out-2")},
+			&warden.ProcessPayload{ExitStatus: proto.Uint32(0)},
+		))
+
+		result, err := RunAndCapture(fakeGordon, "some-handle", "some-script", CaptureOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(result.Stdout).Should(Equal("out-1 out-2"))
+		Ω(result.Stderr).Should(Equal("err"))
+		Ω(result.ExitStatus).Should(BeZero())
+		Ω(result.Truncated).Should(BeFalse())
+	})
+
+	It("passes resource limits and environment variables through", func() {
+		opts := CaptureOptions{
+			ResourceLimits:       ResourceLimits{FileDescriptors: 16},
+			EnvironmentVariables: []EnvironmentVariable{{Key: "FOO", Value: "bar"}},
+		}
+
+		fakeGordon.WhenRunning("some-handle", "some-script", ResourceLimits{}, nil, fake_gordon.RespondWith(
+			&warden.ProcessPayload{ExitStatus: proto.Uint32(0)},
+		))
+
+		_, err := RunAndCapture(fakeGordon, "some-handle", "some-script", opts)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		ran := fakeGordon.ScriptsThatRan()
+		Ω(ran).Should(HaveLen(1))
+		Ω(ran[0].ResourceLimits).Should(Equal(opts.ResourceLimits))
+		Ω(ran[0].EnvironmentVariables).Should(Equal(opts.EnvironmentVariables))
+	})
+
+	Context("when the process exits non-zero", func() {
+		BeforeEach(func() {
+			fakeGordon.WhenRunning("some-handle", "some-script", ResourceLimits{}, nil, fake_gordon.RespondWith(
+				&warden.ProcessPayload{Source: &stdout, Data: proto.String("partial")},
+				&warden.ProcessPayload{Source: &stderr, Data: proto.String("oh no")},
+				&warden.ProcessPayload{ExitStatus: proto.Uint32(42)},
+			))
+		})
+
+		It("returns the result along with an ExitStatusError", func() {
+			result, err := RunAndCapture(fakeGordon, "some-handle", "some-script", CaptureOptions{})
+			Ω(err).Should(Equal(&ExitStatusError{ExitStatus: 42, Stderr: "oh no"}))
+
+			Ω(result.Stdout).Should(Equal("partial"))
+			Ω(result.ExitStatus).Should(Equal(uint32(42)))
+		})
+	})
+
+	Context("when the output exceeds the maximum size", func() {
+		BeforeEach(func() {
+			fakeGordon.WhenRunning("some-handle", "some-script", ResourceLimits{}, nil, fake_gordon.RespondWith(
+				&warden.ProcessPayload{Source: &stdout, Data: proto.String("0123")},
+				&warden.ProcessPayload{Source: &stdout, Data: proto.String("4567")},
+				&warden.ProcessPayload{Source: &stderr, Data: proto.String("ab")},
+				&warden.ProcessPayload{ExitStatus: proto.Uint32(0)},
+			))
+		})
+
+		It("keeps the first bytes of each stream and flags the result", func() {
+			result, err := RunAndCapture(fakeGordon, "some-handle", "some-script", CaptureOptions{
+				MaxOutputBytes: 6,
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(result.Stdout).Should(Equal("012345"))
+			Ω(result.Stderr).Should(Equal("ab"))
+			Ω(result.Truncated).Should(BeTrue())
+		})
+	})
+
+	Context("when the stream ends without an exit status", func() {
+		BeforeEach(func() {
+			fakeGordon.WhenRunning("some-handle", "some-script", ResourceLimits{}, nil, fake_gordon.RespondWith(
+				&warden.ProcessPayload{Source: &stdout, Data: proto.String("hi")},
+			))
+		})
+
+		It("returns ErrNoExitStatus", func() {
+			result, err := RunAndCapture(fakeGordon, "some-handle", "some-script", CaptureOptions{})
+			Ω(err).Should(Equal(ErrNoExitStatus))
+			Ω(result.Stdout).Should(Equal("hi"))
+		})
+	})
+
+	Context("when running fails", func() {
+		disaster := errors.New("oh no!")
+
+		BeforeEach(func() {
+			fakeGordon.WhenRunning("some-handle", "some-script", ResourceLimits{}, nil, func() (uint32, <-chan *warden.ProcessPayload, error) {
+				return 0, nil, disaster
+			})
+		})
+
+		It("returns the error", func() {
+			_, err := RunAndCapture(fakeGordon, "some-handle", "some-script", CaptureOptions{})
+			Ω(err).Should(Equal(disaster))
+		})
+	})
+
+	Context("when the process outlives the timeout", func() {
+		BeforeEach(func() {
+			fakeGordon.WhenRunning("some-handle", "some-script", ResourceLimits{}, nil, func() (uint32, <-chan *warden.ProcessPayload, error) {
+				payloads := make(chan *warden.ProcessPayload, 1)
+				payloads <- &warden.ProcessPayload{Source: &stdout, Data: proto.String("working")}
+
+				return 1, payloads, nil
+			})
+		})
+
+		It("stops the container and returns ErrRunTimedOut", func() {
+			result, err := RunAndCapture(fakeGordon, "some-handle", "some-script", CaptureOptions{
+				Timeout: 50 * time.Millisecond,
+			})
+			Ω(err).Should(Equal(ErrRunTimedOut))
+
+			Ω(result.Stdout).Should(Equal("working"))
+			Ω(result.Duration).Should(BeNumerically(">=", 50*time.Millisecond))
+			Ω(fakeGordon.StoppedHandles()).Should(Equal([]string{"some-handle"}))
+		})
+
+		Context("and stopping fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeGordon.StopError = disaster
+			})
+
+			It("returns the stop error", func() {
+				_, err := RunAndCapture(fakeGordon, "some-handle", "some-script", CaptureOptions{
+					Timeout: 50 * time.Millisecond,
+				})
+				Ω(err).Should(Equal(disaster))
+			})
+		})
+	})
+})
@@ -1,6 +1,10 @@
 package gordon
 
 import (
+	"context"
+	"errors"
+	"io"
+	"sync"
 	"time"
 
 	"code.google.com/p/gogoprotobuf/proto"
@@ -8,13 +12,53 @@ import (
 	"github.com/cloudfoundry-incubator/gordon/connection"
 )
 
+// ResourceLimits are the rlimits a process is run with. Limits left at
+// zero are not sent, leaving warden's defaults in place.
 type ResourceLimits struct {
+	As              uint64
+	Core            uint64
+	Cpu             uint64
+	Data            uint64
+	Fsize           uint64
+	Locks           uint64
+	Memlock         uint64
+	Msgqueue        uint64
+	Nice            uint64
 	FileDescriptors uint64
+	Nproc           uint64
+	Rss             uint64
+	Rtprio          uint64
+	Sigpending      uint64
+	Stack           uint64
 }
 
+// DiskLimits are sent with LimitDisk and returned by GetDiskLimits. Fields
+// left at zero are not sent.
 type DiskLimits struct {
-	ByteLimit  uint64
+	BlockLimit uint64
+	Block      uint64
+	BlockSoft  uint64
+	BlockHard  uint64
+
 	InodeLimit uint64
+	Inode      uint64
+	InodeSoft  uint64
+	InodeHard  uint64
+
+	ByteLimit uint64
+	Byte      uint64
+	ByteSoft  uint64
+	ByteHard  uint64
+}
+
+// MemoryLimits are returned by GetMemoryLimits; a LimitInBytes of 0 means
+// the container is unlimited.
+type MemoryLimits struct {
+	LimitInBytes uint64
+}
+
+type CPULimits struct {
+	LimitInShares uint64
 }
 
 type EnvironmentVariable struct {
@@ -22,35 +66,87 @@ type EnvironmentVariable struct {
 	Value string
 }
 
+// BulkInfoEntry is the result of fetching info for one handle; Err is set
+// instead of Info if, for example, the container went away mid-scan.
+type BulkInfoEntry struct {
+	Info *warden.InfoResponse
+	Err  error
+}
+
 type Client interface {
 	Connect() error
+	Close() error
+	Shutdown(ctx context.Context) error
 
 	Create(properties map[string]string) (*warden.CreateResponse, error)
 	Stop(handle string, background, kill bool) (*warden.StopResponse, error)
 	Destroy(handle string) (*warden.DestroyResponse, error)
 	Run(handle, script string, resourceLimits ResourceLimits, environmentVariables []EnvironmentVariable) (uint32, <-chan *warden.ProcessPayload, error)
 	Attach(handle string, processID uint32) (<-chan *warden.ProcessPayload, error)
+	RunStream(handle, script string, resourceLimits ResourceLimits, environmentVariables []EnvironmentVariable) (*ProcessStream, error)
+	RunWithSpec(handle string, spec RunSpec) (*ProcessStream, error)
+	Exec(handle string, argv []string, environmentVariables []EnvironmentVariable, resourceLimits ResourceLimits) (uint32, <-chan *warden.ProcessPayload, error)
+	AttachStream(handle string, processID uint32) (*ProcessStream, error)
 	NetIn(handle string) (*warden.NetInResponse, error)
+	NetInWithPorts(handle string, hostPort, containerPort uint32) (*warden.NetInResponse, error)
 	LimitMemory(handle string, limit uint64) (*warden.LimitMemoryResponse, error)
 	GetMemoryLimit(handle string) (uint64, error)
+	GetMemoryLimits(handle string) (MemoryLimits, error)
 	LimitCPU(handle string, limitInShares uint64) (*warden.LimitCpuResponse, error)
+	GetCPULimit(handle string) (uint64, error)
+	GetCPULimits(handle string) (CPULimits, error)
 	LimitDisk(handle string, limits DiskLimits) (*warden.LimitDiskResponse, error)
 	GetDiskLimit(handle string) (uint64, error)
+	GetDiskLimits(handle string) (DiskLimits, error)
 	List(filterProperties map[string]string) (*warden.ListResponse, error)
+	GetHandles(filterProperties map[string]string) ([]string, error)
 	Info(handle string) (*warden.InfoResponse, error)
+	GetInfo(handle string) (ContainerInfo, error)
+	BulkInfo(handles []string, concurrency int) map[string]BulkInfoEntry
 	CopyIn(handle, src, dst string) (*warden.CopyInResponse, error)
 	CopyOut(handle, src, dst, owner string) (*warden.CopyOutResponse, error)
+	StreamIn(handle, dst string, r io.Reader) error
+	StreamOut(handle, src string) (io.ReadCloser, error)
+	Signal(handle string, processID uint32, signal string) error
+	Watch(filterProperties map[string]string, interval time.Duration) *Watcher
 }
 
 type client struct {
 	connectionProvider ConnectionProvider
 	connection         chan *connection.Connection
+
+	// requests and streams currently holding a connection
+	inFlight sync.WaitGroup
+
+	// closed when the client stops handing out connections
+	closing chan struct{}
+
+	// closed when in-flight requests and streams are abandoned
+	cancelled chan struct{}
+
+	conns  map[*connection.Connection]bool
+	closed bool
+
+	// signalable processes started by this client whose streams are still
+	// followed, by handle and process ID
+	processes map[processKey]signalTarget
+
+	lock sync.Mutex
 }
 
+var ErrClientClosed = errors.New("client closed")
+
 func NewClient(cp ConnectionProvider) Client {
 	return &client{
 		connectionProvider: cp,
 		connection:         make(chan *connection.Connection),
+
+		closing:   make(chan struct{}),
+		cancelled: make(chan struct{}),
+
+		conns: make(map[*connection.Connection]bool),
+
+		processes: make(map[processKey]signalTarget),
 	}
 }
 
@@ -60,30 +156,54 @@ func (c *client) Connect() error {
 		return err
 	}
 
+	err = c.track(conn)
+	if err != nil {
+		return err
+	}
+
 	go c.serveConnection(conn)
 
 	return nil
 }
 
 func (c *client) Create(properties map[string]string) (*warden.CreateResponse, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
 	defer c.release(conn)
 
 	return conn.Create(properties)
 }
 
 func (c *client) Stop(handle string, background, kill bool) (*warden.StopResponse, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
 	defer c.release(conn)
 
 	return conn.Stop(handle, background, kill)
 }
 
 func (c *client) Destroy(handle string) (*warden.DestroyResponse, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
 	defer c.release(conn)
 
-	return conn.Destroy(handle)
+	response, err := conn.Destroy(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	c.forgetProcesses(handle)
+
+	return response, nil
 }
 
 func convertEnvironmentVariables(environmentVariables []EnvironmentVariable) []*warden.EnvironmentVariable {
@@ -100,79 +220,245 @@ func convertEnvironmentVariables(environmentVariables []EnvironmentVariable) []*
 }
 
 func (c *client) Run(handle, script string, resourceLimits ResourceLimits, environmentVariables []EnvironmentVariable) (uint32, <-chan *warden.ProcessPayload, error) {
-	conn := c.acquireConnection()
+	stream, err := c.RunStream(handle, script, resourceLimits, environmentVariables)
+	if err != nil {
+		return 0, nil, err
+	}
 
-	wardenResourceLimits := &warden.ResourceLimits{}
+	return stream.ProcessID, stream.Payloads, nil
+}
 
-	if resourceLimits.FileDescriptors > 0 {
-		wardenResourceLimits.Nofile = proto.Uint64(resourceLimits.FileDescriptors)
+func (c *client) Exec(handle string, argv []string, environmentVariables []EnvironmentVariable, resourceLimits ResourceLimits) (uint32, <-chan *warden.ProcessPayload, error) {
+	script, err := ExecScript(argv)
+	if err != nil {
+		return 0, nil, err
 	}
 
-	processID, stream, err := conn.Run(handle, script, wardenResourceLimits, convertEnvironmentVariables(environmentVariables))
+	return c.Run(handle, script, resourceLimits, environmentVariables)
+}
+
+func (c *client) RunStream(handle, script string, resourceLimits ResourceLimits, environmentVariables []EnvironmentVariable) (*ProcessStream, error) {
+	return c.RunWithSpec(handle, RunSpec{
+		Script:               script,
+		ResourceLimits:       resourceLimits,
+		EnvironmentVariables: environmentVariables,
+	})
+}
 
+func (c *client) RunWithSpec(handle string, spec RunSpec) (*ProcessStream, error) {
+	script, err := spec.WrappedScript()
 	if err != nil {
-		c.release(conn)
-		return 0, nil, err
+		return nil, err
 	}
 
-	proxy := make(chan *warden.ProcessPayload)
+	environmentVariables := spec.EnvironmentVariables
 
-	go func() {
-		for payload := range stream {
-			proxy <- payload
+	var target signalTarget
+	if spec.Signalable {
+		target, err = newSignalTarget(spec.Privileged)
+		if err != nil {
+			return nil, err
 		}
-		close(proxy)
+
+		environmentVariables = append(environmentVariables, EnvironmentVariable{Key: ProcessTokenEnv, Value: target.token})
+	}
+
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	request := &warden.RunRequest{
+		Handle:  proto.String(handle),
+		Script:  proto.String(script),
+		Rlimits: convertResourceLimits(spec.ResourceLimits),
+		Env:     convertEnvironmentVariables(environmentVariables),
+	}
+
+	if spec.Privileged {
+		request.Privileged = proto.Bool(true)
+	}
+
+	processID, payloads, err := conn.RunProcess(request)
+	if err != nil {
 		c.release(conn)
-	}()
+		return nil, err
+	}
 
-	return processID, proxy, err
+	if spec.Signalable {
+		c.rememberProcess(handle, processID, target)
+	}
+
+	return c.stream(conn, handle, processID, payloads), nil
 }
 
-func (c *client) Attach(handle string, jobID uint32) (<-chan *warden.ProcessPayload, error) {
-	conn := c.acquireConnection()
+func convertResourceLimits(limits ResourceLimits) *warden.ResourceLimits {
+	return &warden.ResourceLimits{
+		As:         optionalUint64(limits.As),
+		Core:       optionalUint64(limits.Core),
+		Cpu:        optionalUint64(limits.Cpu),
+		Data:       optionalUint64(limits.Data),
+		Fsize:      optionalUint64(limits.Fsize),
+		Locks:      optionalUint64(limits.Locks),
+		Memlock:    optionalUint64(limits.Memlock),
+		Msgqueue:   optionalUint64(limits.Msgqueue),
+		Nice:       optionalUint64(limits.Nice),
+		Nofile:     optionalUint64(limits.FileDescriptors),
+		Nproc:      optionalUint64(limits.Nproc),
+		Rss:        optionalUint64(limits.Rss),
+		Rtprio:     optionalUint64(limits.Rtprio),
+		Sigpending: optionalUint64(limits.Sigpending),
+		Stack:      optionalUint64(limits.Stack),
+	}
+}
 
-	stream, err := conn.Attach(handle, jobID)
+func (c *client) Attach(handle string, processID uint32) (<-chan *warden.ProcessPayload, error) {
+	stream, err := c.AttachStream(handle, processID)
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.Payloads, nil
+}
+
+func (c *client) AttachStream(handle string, processID uint32) (*ProcessStream, error) {
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	payloads, err := conn.Attach(handle, processID)
 	if err != nil {
 		c.release(conn)
 		return nil, err
 	}
 
+	return c.stream(conn, handle, processID, payloads), nil
+}
+
+func (c *client) stream(conn *connection.Connection, handle string, processID uint32, payloads <-chan *warden.ProcessPayload) *ProcessStream {
 	proxy := make(chan *warden.ProcessPayload)
+	detached := make(chan struct{})
+	done := make(chan struct{})
 
 	go func() {
-		for payload := range stream {
-			proxy <- payload
-		}
-		close(proxy)
-		c.release(conn)
+		c.forward(conn, handle, processID, payloads, proxy, detached)
+		close(done)
 	}()
 
-	return proxy, err
+	return NewProcessStream(processID, proxy, func() {
+		close(detached)
+		<-done
+	})
+}
+
+func (c *client) forward(conn *connection.Connection, handle string, processID uint32, payloads <-chan *warden.ProcessPayload, proxy chan<- *warden.ProcessPayload, detached <-chan struct{}) {
+	defer close(proxy)
+
+	// once the stream stops, whether the process exits, the connection is
+	// lost or the stream is detached, there's no telling when the process
+	// goes away, so it's no longer signalled by token
+	defer c.forgetProcess(handle, processID)
+
+	for {
+		select {
+		case payload, ok := <-payloads:
+			if !ok {
+				c.release(conn)
+				return
+			}
+
+			select {
+			case proxy <- payload:
+			case <-detached:
+				c.abandon(conn, payloads)
+				return
+			case <-c.cancelled:
+				c.abandon(conn, payloads)
+				return
+			}
+
+		case <-detached:
+			c.abandon(conn, payloads)
+			return
+
+		case <-c.cancelled:
+			c.abandon(conn, payloads)
+			return
+		}
+	}
+}
+
+// abandon gives up on a stream part way through; the rest of its payloads
+// would still arrive on the connection, so it can't be reused
+func (c *client) abandon(conn *connection.Connection, payloads <-chan *warden.ProcessPayload) {
+	c.discard(conn)
+
+	for _ = range payloads {
+	}
+
+	c.inFlight.Done()
 }
 
 func (c *client) NetIn(handle string) (*warden.NetInResponse, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
 	defer c.release(conn)
 
 	return conn.NetIn(handle)
 }
 
+func (c *client) NetInWithPorts(handle string, hostPort, containerPort uint32) (*warden.NetInResponse, error) {
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.release(conn)
+
+	return conn.NetInWithPorts(handle, hostPort, containerPort)
+}
+
 func (c *client) LimitMemory(handle string, limit uint64) (*warden.LimitMemoryResponse, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
 	defer c.release(conn)
 
 	return conn.LimitMemory(handle, limit)
 }
 
 func (c *client) GetMemoryLimit(handle string) (uint64, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return 0, err
+	}
+
 	defer c.release(conn)
 
 	return conn.GetMemoryLimit(handle)
 }
 
+func (c *client) GetMemoryLimits(handle string) (MemoryLimits, error) {
+	limit, err := c.GetMemoryLimit(handle)
+	if err != nil {
+		return MemoryLimits{}, err
+	}
+
+	return MemoryLimits{LimitInBytes: limit}, nil
+}
+
 func (c *client) LimitCPU(handle string, limitInShares uint64) (*warden.LimitCpuResponse, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
 	defer c.release(conn)
 
 	limitRequest := &warden.LimitCpuRequest{
@@ -183,92 +469,407 @@ func (c *client) LimitCPU(handle string, limitInShares uint64) (*warden.LimitCpu
 	return conn.LimitCPU(limitRequest)
 }
 
-func (c *client) LimitDisk(handle string, limits DiskLimits) (*warden.LimitDiskResponse, error) {
-	conn := c.acquireConnection()
+func (c *client) GetCPULimit(handle string) (uint64, error) {
+	limits, err := c.GetCPULimits(handle)
+	if err != nil {
+		return 0, err
+	}
+
+	return limits.LimitInShares, nil
+}
+
+// GetCPULimits sends a LimitCpuRequest without a limit, which warden
+// answers with the current one.
+func (c *client) GetCPULimits(handle string) (CPULimits, error) {
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return CPULimits{}, err
+	}
+
 	defer c.release(conn)
 
-	limitRequest := &warden.LimitDiskRequest{
+	res, err := conn.LimitCPU(&warden.LimitCpuRequest{
 		Handle: proto.String(handle),
+	})
+	if err != nil {
+		return CPULimits{}, err
 	}
 
-	if limits.ByteLimit > 0 {
-		limitRequest.ByteLimit = proto.Uint64(limits.ByteLimit)
+	return CPULimits{LimitInShares: res.GetLimitInShares()}, nil
+}
+
+func (c *client) LimitDisk(handle string, limits DiskLimits) (*warden.LimitDiskResponse, error) {
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
 	}
 
-	if limits.InodeLimit > 0 {
-		limitRequest.InodeLimit = proto.Uint64(limits.InodeLimit)
+	defer c.release(conn)
+
+	limitRequest := &warden.LimitDiskRequest{
+		Handle: proto.String(handle),
+
+		BlockLimit: optionalUint64(limits.BlockLimit),
+		Block:      optionalUint64(limits.Block),
+		BlockSoft:  optionalUint64(limits.BlockSoft),
+		BlockHard:  optionalUint64(limits.BlockHard),
+
+		InodeLimit: optionalUint64(limits.InodeLimit),
+		Inode:      optionalUint64(limits.Inode),
+		InodeSoft:  optionalUint64(limits.InodeSoft),
+		InodeHard:  optionalUint64(limits.InodeHard),
+
+		ByteLimit: optionalUint64(limits.ByteLimit),
+		Byte:      optionalUint64(limits.Byte),
+		ByteSoft:  optionalUint64(limits.ByteSoft),
+		ByteHard:  optionalUint64(limits.ByteHard),
 	}
 
 	return conn.LimitDisk(limitRequest)
 }
 
+func optionalUint64(val uint64) *uint64 {
+	if val == 0 {
+		return nil
+	}
+
+	return proto.Uint64(val)
+}
+
 func (c *client) GetDiskLimit(handle string) (uint64, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return 0, err
+	}
+
 	defer c.release(conn)
 
 	return conn.GetDiskLimit(handle)
 }
 
+func (c *client) GetDiskLimits(handle string) (DiskLimits, error) {
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return DiskLimits{}, err
+	}
+
+	defer c.release(conn)
+
+	res, err := conn.LimitDisk(&warden.LimitDiskRequest{
+		Handle: proto.String(handle),
+	})
+	if err != nil {
+		return DiskLimits{}, err
+	}
+
+	return DiskLimits{
+		BlockLimit: res.GetBlockLimit(),
+		Block:      res.GetBlock(),
+		BlockSoft:  res.GetBlockSoft(),
+		BlockHard:  res.GetBlockHard(),
+
+		InodeLimit: res.GetInodeLimit(),
+		Inode:      res.GetInode(),
+		InodeSoft:  res.GetInodeSoft(),
+		InodeHard:  res.GetInodeHard(),
+
+		ByteLimit: res.GetByteLimit(),
+		Byte:      res.GetByte(),
+		ByteSoft:  res.GetByteSoft(),
+		ByteHard:  res.GetByteHard(),
+	}, nil
+}
+
 func (c *client) List(filterProperties map[string]string) (*warden.ListResponse, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
 	defer c.release(conn)
 
 	return conn.List(filterProperties)
 }
 
 func (c *client) Info(handle string) (*warden.InfoResponse, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
 	defer c.release(conn)
 
 	return conn.Info(handle)
 }
 
+func (c *client) GetHandles(filterProperties map[string]string) ([]string, error) {
+	res, err := c.List(filterProperties)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.GetHandles(), nil
+}
+
+func (c *client) GetInfo(handle string) (ContainerInfo, error) {
+	res, err := c.Info(handle)
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+
+	return NewContainerInfo(res), nil
+}
+
+// BulkInfo fetches info for every handle, with up to concurrency requests
+// in flight, each on its own pooled connection.
+func (c *client) BulkInfo(handles []string, concurrency int) map[string]BulkInfoEntry {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make(map[string]BulkInfoEntry, len(handles))
+	resultsLock := sync.Mutex{}
+
+	work := make(chan string)
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < concurrency && i < len(handles); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for handle := range work {
+				info, err := c.Info(handle)
+
+				resultsLock.Lock()
+				results[handle] = BulkInfoEntry{Info: info, Err: err}
+				resultsLock.Unlock()
+			}
+		}()
+	}
+
+	for _, handle := range handles {
+		work <- handle
+	}
+
+	close(work)
+
+	wg.Wait()
+
+	return results
+}
+
 func (c *client) CopyIn(handle, src, dst string) (*warden.CopyInResponse, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
 	defer c.release(conn)
 
 	return conn.CopyIn(handle, src, dst)
 }
 
 func (c *client) CopyOut(handle, src, dst, owner string) (*warden.CopyOutResponse, error) {
-	conn := c.acquireConnection()
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return nil, err
+	}
+
 	defer c.release(conn)
 
 	return conn.CopyOut(handle, src, dst, owner)
 }
 
+// Watch polls the containers matching filterProperties every interval and
+// emits the differences; see Watcher.
+func (c *client) Watch(filterProperties map[string]string, interval time.Duration) *Watcher {
+	return NewWatcher(c, filterProperties, interval)
+}
+
+// Shutdown stops handing out connections and waits for in-flight requests
+// and streams to finish. If ctx is done first, they are cancelled by closing
+// their connections. Either way, every connection the client opened is
+// closed by the time Shutdown returns.
+func (c *client) Shutdown(ctx context.Context) error {
+	c.beginClosing()
+
+	done := make(chan struct{})
+	go func() {
+		c.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		c.closeConnections()
+		return nil
+
+	case <-ctx.Done():
+		c.cancel()
+		<-done
+		c.closeConnections()
+		return ctx.Err()
+	}
+}
+
+// Close immediately closes every connection, cancelling in-flight requests
+// and streams.
+func (c *client) Close() error {
+	c.beginClosing()
+	c.cancel()
+	c.inFlight.Wait()
+	c.closeConnections()
+
+	return nil
+}
+
+func (c *client) beginClosing() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	close(c.closing)
+}
+
+func (c *client) cancel() {
+	c.lock.Lock()
+
+	select {
+	case <-c.cancelled:
+	default:
+		close(c.cancelled)
+	}
+
+	c.lock.Unlock()
+
+	c.closeConnections()
+}
+
+func (c *client) isClosed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.closed
+}
+
+func (c *client) closeConnections() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for conn := range c.conns {
+		conn.Close()
+		delete(c.conns, conn)
+	}
+}
+
+func (c *client) track(conn *connection.Connection) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	select {
+	case <-c.cancelled:
+		conn.Close()
+		return ErrClientClosed
+	default:
+	}
+
+	c.conns[conn] = true
+
+	return nil
+}
+
+func (c *client) discard(conn *connection.Connection) {
+	c.lock.Lock()
+	delete(c.conns, conn)
+	c.lock.Unlock()
+
+	conn.Close()
+}
+
 func (c *client) serveConnection(conn *connection.Connection) {
 	select {
 	case <-conn.Disconnected:
+		c.discard(conn)
 
 	case c.connection <- conn:
 
 	case <-time.After(5 * time.Second):
-		conn.Close()
+		c.discard(conn)
+
+	case <-c.closing:
+		c.discard(conn)
 	}
 }
 
 func (c *client) release(conn *connection.Connection) {
+	defer c.inFlight.Done()
+
+	// e.g. timed out mid-request; never hand it out again
+	if !conn.Alive() || c.isClosed() {
+		c.discard(conn)
+		return
+	}
+
 	go c.serveConnection(conn)
 }
 
-func (c *client) acquireConnection() *connection.Connection {
-	select {
-	case conn := <-c.connection:
-		return conn
+func (c *client) acquireConnection() (*connection.Connection, error) {
+	c.lock.Lock()
+
+	if c.closed {
+		c.lock.Unlock()
+		return nil, ErrClientClosed
+	}
+
+	c.inFlight.Add(1)
 
-	case <-time.After(1 * time.Second):
-		return c.connect()
+	c.lock.Unlock()
+
+	for {
+		select {
+		case conn := <-c.connection:
+			// the peer may have gone away while the connection sat idle
+			if !conn.Alive() {
+				c.discard(conn)
+				continue
+			}
+
+			return conn, nil
+
+		case <-time.After(1 * time.Second):
+			conn, err := c.connect()
+			if err != nil {
+				c.inFlight.Done()
+				return nil, err
+			}
+
+			return conn, nil
+
+		case <-c.closing:
+			c.inFlight.Done()
+			return nil, ErrClientClosed
+		}
 	}
 }
 
-func (c *client) connect() *connection.Connection {
+func (c *client) connect() (*connection.Connection, error) {
 	for {
 		conn, err := c.connectionProvider.ProvideConnection()
 		if err == nil {
-			return conn
+			return conn, c.track(conn)
 		}
 
-		time.Sleep(500 * time.Millisecond)
+		select {
+		case <-time.After(500 * time.Millisecond):
+		case <-c.closing:
+			return nil, ErrClientClosed
+		}
 	}
 }
@@ -2,12 +2,20 @@ package gordon_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"io"
+	"io/ioutil"
+	"net"
 	"runtime"
+	"sync"
+	"time"
 
+	"github.com/cloudfoundry-incubator/gordon/connection"
 	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
 
 	. "github.com/cloudfoundry-incubator/gordon"
+	. "github.com/cloudfoundry-incubator/gordon/test_helpers"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -34,6 +42,33 @@ var _ = Describe("Client", func() {
 		client = fake_gordon.New()
 	})
 
+	It("should have a fake that can be attached to", func() {
+		fakeGordon := fake_gordon.New()
+
+		payloads := make(chan *warden.ProcessPayload, 1)
+		payloads <- &warden.ProcessPayload{ExitStatus: proto.Uint32(0)}
+		close(payloads)
+
+		fakeGordon.SetAttachPayloads("foo", 42, payloads)
+
+		stream, err := fakeGordon.AttachStream("foo", 42)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(stream.ProcessID).Should(BeNumerically("==", 42))
+
+		var payload *warden.ProcessPayload
+		Eventually(stream.Payloads).Should(Receive(&payload))
+		Ω(payload.GetExitStatus()).Should(BeZero())
+
+		unknown, err := fakeGordon.AttachStream("foo", 43)
+		Ω(err).ShouldNot(HaveOccurred())
+		Eventually(unknown.Payloads).Should(BeClosed())
+
+		Ω(fakeGordon.ThingsAttachedTo()).Should(Equal([]*fake_gordon.Attached{
+			{Handle: "foo", ProcessID: 42},
+			{Handle: "foo", ProcessID: 43},
+		}))
+	})
+
 	Describe("Connect", func() {
 		Context("with a successful provider", func() {
 			BeforeEach(func() {
@@ -58,6 +93,81 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("Closing", func() {
+		var fakeConn *FakeConn
+
+		BeforeEach(func() {
+			stdout := warden.ProcessPayload_stdout
+
+			fakeConn = &FakeConn{
+				ReadBuffer: warden.Messages(
+					&warden.ProcessPayload{ProcessId: proto.Uint32(1721)},
+					&warden.ProcessPayload{
+						ProcessId: proto.Uint32(1721),
+						Source:    &stdout,
+						Data:      proto.String("some data for stdout"),
+					},
+					&warden.ProcessPayload{
+						ProcessId:  proto.Uint32(1721),
+						ExitStatus: proto.Uint32(42),
+					},
+				),
+				WriteBuffer: writeBuffer,
+			}
+
+			client = NewClient(&FakeConnectionProvider{connection: connection.New(fakeConn)})
+			err := client.Connect()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should close idle connections and refuse further requests", func() {
+			err := client.Close()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Eventually(func() bool { return fakeConn.IsClosed() }).Should(BeTrue())
+
+			_, err = client.Create(nil)
+			Ω(err).Should(Equal(ErrClientClosed))
+		})
+
+		Describe("Shutdown", func() {
+			It("should wait for in-flight streams to finish", func() {
+				_, responses, err := client.Run("foo", "echo some data for stdout", ResourceLimits{}, nil)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				shutDown := make(chan error)
+				go func() {
+					shutDown <- client.Shutdown(context.Background())
+				}()
+
+				Consistently(shutDown, 200*time.Millisecond).ShouldNot(Receive())
+
+				_, err = client.Create(nil)
+				Ω(err).Should(Equal(ErrClientClosed))
+
+				for _ = range responses {
+				}
+
+				Eventually(shutDown).Should(Receive(BeNil()))
+				Ω(fakeConn.IsClosed()).Should(BeTrue())
+			})
+
+			It("should cancel in-flight streams at the deadline", func() {
+				_, responses, err := client.Run("foo", "echo some data for stdout", ResourceLimits{}, nil)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				defer cancel()
+
+				err = client.Shutdown(ctx)
+				Ω(err).Should(Equal(context.DeadlineExceeded))
+
+				Eventually(responses).Should(BeClosed())
+				Ω(fakeConn.IsClosed()).Should(BeTrue())
+			})
+		})
+	})
+
 	Describe("The container lifecycle", func() {
 		BeforeEach(func() {
 			provider = NewFakeConnectionProvider(
@@ -195,6 +305,114 @@ var _ = Describe("Client", func() {
 				Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
 			})
 		})
+
+		Context("When resource limits are set", func() {
+			limitCases := []struct {
+				name     string
+				limits   ResourceLimits
+				expected *warden.ResourceLimits
+			}{
+				{"as", ResourceLimits{As: 1}, &warden.ResourceLimits{As: proto.Uint64(1)}},
+				{"core", ResourceLimits{Core: 2}, &warden.ResourceLimits{Core: proto.Uint64(2)}},
+				{"cpu", ResourceLimits{Cpu: 3}, &warden.ResourceLimits{Cpu: proto.Uint64(3)}},
+				{"data", ResourceLimits{Data: 4}, &warden.ResourceLimits{Data: proto.Uint64(4)}},
+				{"fsize", ResourceLimits{Fsize: 5}, &warden.ResourceLimits{Fsize: proto.Uint64(5)}},
+				{"locks", ResourceLimits{Locks: 6}, &warden.ResourceLimits{Locks: proto.Uint64(6)}},
+				{"memlock", ResourceLimits{Memlock: 7}, &warden.ResourceLimits{Memlock: proto.Uint64(7)}},
+				{"msgqueue", ResourceLimits{Msgqueue: 8}, &warden.ResourceLimits{Msgqueue: proto.Uint64(8)}},
+				{"nice", ResourceLimits{Nice: 9}, &warden.ResourceLimits{Nice: proto.Uint64(9)}},
+				{"nofile", ResourceLimits{FileDescriptors: 10}, &warden.ResourceLimits{Nofile: proto.Uint64(10)}},
+				{"nproc", ResourceLimits{Nproc: 11}, &warden.ResourceLimits{Nproc: proto.Uint64(11)}},
+				{"rss", ResourceLimits{Rss: 12}, &warden.ResourceLimits{Rss: proto.Uint64(12)}},
+				{"rtprio", ResourceLimits{Rtprio: 13}, &warden.ResourceLimits{Rtprio: proto.Uint64(13)}},
+				{"sigpending", ResourceLimits{Sigpending: 14}, &warden.ResourceLimits{Sigpending: proto.Uint64(14)}},
+				{"stack", ResourceLimits{Stack: 15}, &warden.ResourceLimits{Stack: proto.Uint64(15)}},
+				{
+					"several",
+					ResourceLimits{As: 1, FileDescriptors: 10, Stack: 15},
+					&warden.ResourceLimits{As: proto.Uint64(1), Nofile: proto.Uint64(10), Stack: proto.Uint64(15)},
+				},
+			}
+
+			for _, limitCase := range limitCases {
+				limitCase := limitCase
+
+				It("should only populate the "+limitCase.name+" limit", func() {
+					_, _, err := client.Run("foo", "echo some data for stdout", limitCase.limits, nil)
+					Ω(err).ShouldNot(HaveOccurred())
+
+					expectedWriteBufferContents := string(warden.Messages(
+						&warden.RunRequest{
+							Handle:  proto.String("foo"),
+							Script:  proto.String("echo some data for stdout"),
+							Rlimits: limitCase.expected,
+						},
+					).Bytes())
+
+					Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
+				})
+			}
+		})
+	})
+
+	Describe("Running with a spec", func() {
+		BeforeEach(func() {
+			provider = NewFakeConnectionProvider(
+				warden.Messages(
+					&warden.ProcessPayload{ProcessId: proto.Uint32(1721)},
+					&warden.ProcessPayload{ProcessId: proto.Uint32(1721), ExitStatus: proto.Uint32(0)},
+				),
+				writeBuffer,
+			)
+
+			client = NewClient(provider)
+			err := client.Connect()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should send the privileged flag, rlimits and wrapped script", func() {
+			stream, err := client.RunWithSpec("foo", RunSpec{
+				Script:     "whoami",
+				Privileged: true,
+				Dir:        "/app",
+				User:       "vcap",
+				ResourceLimits: ResourceLimits{
+					As:    1024,
+					Nproc: 16,
+				},
+				EnvironmentVariables: []EnvironmentVariable{
+					{Key: "HOME", Value: "/app"},
+				},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stream.ProcessID).Should(BeNumerically("==", 1721))
+
+			Eventually(stream.Payloads).Should(BeClosed())
+
+			expectedWriteBufferContents := string(warden.Messages(
+				&warden.RunRequest{
+					Handle:     proto.String("foo"),
+					Script:     proto.String("exec su 'vcap' -s /bin/sh -c 'cd '\"'\"'/app'\"'\"' || exit 1\nwhoami'"),
+					Privileged: proto.Bool(true),
+					Rlimits: &warden.ResourceLimits{
+						As:    proto.Uint64(1024),
+						Nproc: proto.Uint64(16),
+					},
+					Env: []*warden.EnvironmentVariable{
+						{Key: proto.String("HOME"), Value: proto.String("/app")},
+					},
+				},
+			).Bytes())
+
+			Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
+		})
+
+		It("should refuse to switch user without privileges", func() {
+			_, err := client.RunWithSpec("foo", RunSpec{Script: "whoami", User: "root"})
+			Ω(err).Should(Equal(ErrUserRequiresPrivileged))
+
+			Ω(writeBuffer.Len()).Should(BeZero())
+		})
 	})
 
 	Describe("Attaching", func() {
@@ -254,6 +472,268 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("Detaching from a stream", func() {
+		var forwarders, readers func() []string
+
+		BeforeEach(func() {
+			forwarders = NewGoroutines("gordon.(*client).stream")
+			readers = NewGoroutines("connection.(*Connection)")
+
+			// the process never exits
+			conn := NewPipeConn(warden.Messages(
+				&warden.ProcessPayload{ProcessId: proto.Uint32(1721)},
+				&warden.ProcessPayload{
+					ProcessId: proto.Uint32(1721),
+					Source:    &stdout,
+					Data:      proto.String("some data for stdout"),
+				},
+				&warden.ProcessPayload{
+					ProcessId: proto.Uint32(1721),
+					Source:    &stderr,
+					Data:      proto.String("some data for stderr"),
+				},
+			))
+
+			client = NewClient(&FakeConnectionProvider{connection: connection.New(conn)})
+			err := client.Connect()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should tear down the stream after the consumer stops reading", func() {
+			stream, err := client.RunStream("foo", "tail -f /dev/null", ResourceLimits{}, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stream.ProcessID).Should(BeNumerically("==", 1721))
+			Ω(forwarders()).Should(HaveLen(1))
+
+			res := <-stream.Payloads
+			Ω(res.GetData()).Should(Equal("some data for stdout"))
+
+			stream.Detach()
+
+			Eventually(stream.Payloads).Should(BeClosed())
+			Eventually(forwarders).Should(BeEmpty())
+			Eventually(readers).Should(BeEmpty())
+		})
+
+		It("should tear down the stream when the consumer never reads", func() {
+			stream, err := client.RunStream("foo", "tail -f /dev/null", ResourceLimits{}, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			// let the stream block delivering output that nobody reads
+			time.Sleep(100 * time.Millisecond)
+
+			stream.Detach()
+
+			Eventually(stream.Payloads).Should(BeClosed())
+			Eventually(forwarders).Should(BeEmpty())
+			Eventually(readers).Should(BeEmpty())
+		})
+
+		It("should tear down attached streams", func() {
+			stream, err := client.AttachStream("foo", 1721)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stream.ProcessID).Should(BeNumerically("==", 1721))
+
+			stream.Detach()
+			stream.Detach()
+
+			Eventually(stream.Payloads).Should(BeClosed())
+			Eventually(forwarders).Should(BeEmpty())
+			Eventually(readers).Should(BeEmpty())
+		})
+	})
+
+	Describe("Streaming in", func() {
+		var exitStatus uint32
+
+		BeforeEach(func() {
+			exitStatus = 0
+		})
+
+		JustBeforeEach(func() {
+			provider = NewFakeConnectionProvider(
+				warden.Messages(
+					&warden.ProcessPayload{ProcessId: proto.Uint32(42)},
+					&warden.ProcessPayload{ProcessId: proto.Uint32(42), Source: &stderr, Data: proto.String("no space left")},
+					&warden.ProcessPayload{ProcessId: proto.Uint32(42), ExitStatus: proto.Uint32(exitStatus)},
+				),
+				writeBuffer,
+			)
+
+			client = NewClient(provider)
+			err := client.Connect()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should pipe the archive into tar in the container", func() {
+			err := client.StreamIn("foo", "/some/dst", bytes.NewBufferString("some-tar-data"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			stdin := warden.ProcessPayload_stdin
+
+			expectedWriteBufferContents := string(warden.Messages(
+				&warden.RunRequest{
+					Handle:  proto.String("foo"),
+					Script:  proto.String("mkdir -p '/some/dst' && tar xf - -C '/some/dst'"),
+					Rlimits: &warden.ResourceLimits{},
+				},
+				&warden.ProcessPayload{ProcessId: proto.Uint32(42), Source: &stdin, Data: proto.String("some-tar-data")},
+				&warden.ProcessPayload{ProcessId: proto.Uint32(42), Source: &stdin},
+			).Bytes())
+
+			Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
+		})
+
+		Context("when tar fails", func() {
+			BeforeEach(func() {
+				exitStatus = 2
+			})
+
+			It("should return its exit status and stderr", func() {
+				err := client.StreamIn("foo", "/some/dst", bytes.NewBufferString("some-tar-data"))
+				Ω(err).Should(Equal(&ExitStatusError{ExitStatus: 2, Stderr: "no space left"}))
+			})
+		})
+
+		Context("when the archive never finishes arriving", func() {
+			It("should give up when the client is shut down", func() {
+				reading := make(chan struct{})
+				archive, _ := io.Pipe()
+
+				streamed := make(chan error)
+				go func() {
+					streamed <- client.StreamIn("foo", "/some/dst", &signallingReader{Reader: archive, reading: reading})
+				}()
+
+				Eventually(reading).Should(BeClosed())
+
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				defer cancel()
+
+				shutDown := make(chan error)
+				go func() {
+					shutDown <- client.Shutdown(ctx)
+				}()
+
+				Eventually(shutDown).Should(Receive(Equal(context.DeadlineExceeded)))
+				Eventually(streamed).Should(Receive(Equal(ErrClientClosed)))
+			})
+		})
+	})
+
+	Describe("Streaming out", func() {
+		var exitStatus uint32
+
+		BeforeEach(func() {
+			exitStatus = 0
+		})
+
+		JustBeforeEach(func() {
+			provider = NewFakeConnectionProvider(
+				warden.Messages(
+					&warden.ProcessPayload{ProcessId: proto.Uint32(42)},
+					&warden.ProcessPayload{ProcessId: proto.Uint32(42), Source: &stdout, Data: proto.String("some-")},
+					&warden.ProcessPayload{ProcessId: proto.Uint32(42), Source: &stdout, Data: proto.String("tar-data")},
+					&warden.ProcessPayload{ProcessId: proto.Uint32(42), ExitStatus: proto.Uint32(exitStatus)},
+				),
+				writeBuffer,
+			)
+
+			client = NewClient(provider)
+			err := client.Connect()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should read the archive from tar in the container", func() {
+			reader, err := client.StreamOut("foo", "/some/src")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			data, err := ioutil.ReadAll(reader)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(data)).Should(Equal("some-tar-data"))
+
+			Ω(reader.Close()).Should(Succeed())
+
+			expectedWriteBufferContents := string(warden.Messages(
+				&warden.RunRequest{
+					Handle:  proto.String("foo"),
+					Script:  proto.String("tar cf - -C '/some' 'src'"),
+					Rlimits: &warden.ResourceLimits{},
+				},
+			).Bytes())
+
+			Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
+		})
+
+		It("should archive a directory given with a trailing slash by its name", func() {
+			reader, err := client.StreamOut("foo", "/some/src/")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = ioutil.ReadAll(reader)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			expectedWriteBufferContents := string(warden.Messages(
+				&warden.RunRequest{
+					Handle:  proto.String("foo"),
+					Script:  proto.String("tar cf - -C '/some' 'src'"),
+					Rlimits: &warden.ResourceLimits{},
+				},
+			).Bytes())
+
+			Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
+		})
+
+		Context("when tar fails", func() {
+			BeforeEach(func() {
+				exitStatus = 2
+			})
+
+			It("should fail the read", func() {
+				reader, err := client.StreamOut("foo", "/some/src")
+				Ω(err).ShouldNot(HaveOccurred())
+
+				_, err = ioutil.ReadAll(reader)
+				Ω(err).Should(Equal(&ExitStatusError{ExitStatus: 2}))
+			})
+		})
+	})
+
+	Describe("Mapping ports", func() {
+		BeforeEach(func() {
+			provider = NewFakeConnectionProvider(
+				warden.Messages(
+					&warden.NetInResponse{
+						HostPort:      proto.Uint32(2222),
+						ContainerPort: proto.Uint32(22),
+					},
+				),
+				writeBuffer,
+			)
+
+			client = NewClient(provider)
+			err := client.Connect()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("sends the requested ports", func() {
+			res, err := client.NetInWithPorts("foo", 2222, 22)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(res.GetHostPort()).Should(BeNumerically("==", 2222))
+			Ω(res.GetContainerPort()).Should(BeNumerically("==", 22))
+
+			expectedWriteBufferContents := string(warden.Messages(
+				&warden.NetInRequest{
+					Handle:        proto.String("foo"),
+					HostPort:      proto.Uint32(2222),
+					ContainerPort: proto.Uint32(22),
+				},
+			).Bytes())
+
+			Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
+		})
+	})
+
 	Describe("LimitingCPU", func() {
 		BeforeEach(func() {
 			provider = NewFakeConnectionProvider(
@@ -283,6 +763,111 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("Getting the CPU limit", func() {
+		BeforeEach(func() {
+			provider = NewFakeConnectionProvider(
+				warden.Messages(
+					&warden.LimitCpuResponse{LimitInShares: proto.Uint64(10)},
+				),
+				writeBuffer,
+			)
+
+			client = NewClient(provider)
+			err := client.Connect()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should send a request without a limit and return the current one", func() {
+			limit, err := client.GetCPULimit("foo")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(limit).Should(BeNumerically("==", 10))
+
+			expectedWriteBufferContents := string(warden.Messages(
+				&warden.LimitCpuRequest{
+					Handle: proto.String("foo"),
+				},
+			).Bytes())
+
+			Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
+		})
+	})
+
+	Describe("Getting the memory limits", func() {
+		BeforeEach(func() {
+			provider = NewFakeConnectionProvider(
+				warden.Messages(
+					&warden.LimitMemoryResponse{LimitInBytes: proto.Uint64(1024)},
+				),
+				writeBuffer,
+			)
+
+			client = NewClient(provider)
+			err := client.Connect()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should return the current limits", func() {
+			limits, err := client.GetMemoryLimits("foo")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(limits).Should(Equal(MemoryLimits{LimitInBytes: 1024}))
+		})
+	})
+
+	Describe("Getting the disk limits", func() {
+		BeforeEach(func() {
+			provider = NewFakeConnectionProvider(
+				warden.Messages(
+					&warden.LimitDiskResponse{
+						BlockLimit: proto.Uint64(1),
+						Block:      proto.Uint64(2),
+						BlockSoft:  proto.Uint64(3),
+						BlockHard:  proto.Uint64(4),
+						InodeLimit: proto.Uint64(5),
+						Inode:      proto.Uint64(6),
+						InodeSoft:  proto.Uint64(7),
+						InodeHard:  proto.Uint64(8),
+						ByteLimit:  proto.Uint64(9),
+						Byte:       proto.Uint64(10),
+						ByteSoft:   proto.Uint64(11),
+						ByteHard:   proto.Uint64(12),
+					},
+				),
+				writeBuffer,
+			)
+
+			client = NewClient(provider)
+			err := client.Connect()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should return every limit", func() {
+			limits, err := client.GetDiskLimits("foo")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(limits).Should(Equal(DiskLimits{
+				BlockLimit: 1,
+				Block:      2,
+				BlockSoft:  3,
+				BlockHard:  4,
+				InodeLimit: 5,
+				Inode:      6,
+				InodeSoft:  7,
+				InodeHard:  8,
+				ByteLimit:  9,
+				Byte:       10,
+				ByteSoft:   11,
+				ByteHard:   12,
+			}))
+
+			expectedWriteBufferContents := string(warden.Messages(
+				&warden.LimitDiskRequest{
+					Handle: proto.String("foo"),
+				},
+			).Bytes())
+
+			Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
+		})
+	})
+
 	Describe("LimitingDisk", func() {
 		BeforeEach(func() {
 			provider = NewFakeConnectionProvider(
@@ -335,6 +920,26 @@ var _ = Describe("Client", func() {
 			})
 		})
 
+		Context("when block limits are specified", func() {
+			It("should send them along", func() {
+				_, err := client.LimitDisk("foo", DiskLimits{
+					BlockSoft: 100,
+					BlockHard: 200,
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				expectedWriteBufferContents := string(warden.Messages(
+					&warden.LimitDiskRequest{
+						Handle:    proto.String("foo"),
+						BlockSoft: proto.Uint64(100),
+						BlockHard: proto.Uint64(200),
+					},
+				).Bytes())
+
+				Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
+			})
+		})
+
 		Context("when only the inode limit is specified", func() {
 			It("should limit the inodes only", func() {
 				_, err := client.LimitDisk("foo", DiskLimits{
@@ -389,6 +994,12 @@ var _ = Describe("Client", func() {
 
 				Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
 			})
+
+			It("should return just the handles", func() {
+				handles, err := client.GetHandles(nil)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(handles).Should(Equal([]string{"container1", "container6"}))
+			})
 		})
 
 		Describe("Getting info for a specific container", func() {
@@ -421,6 +1032,174 @@ var _ = Describe("Client", func() {
 
 				Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
 			})
+
+			It("should convert the response to a ContainerInfo", func() {
+				info, err := client.GetInfo("handle")
+
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(info.State).Should(Equal("stopped"))
+			})
+		})
+
+		Describe("Getting info for many containers", func() {
+			BeforeEach(func() {
+				provider = NewFakeConnectionProvider(
+					warden.Messages(
+						&warden.InfoResponse{State: proto.String("active")},
+						&warden.ErrorResponse{Message: proto.String("unknown handle: b")},
+						&warden.InfoResponse{State: proto.String("stopped")},
+					),
+					writeBuffer,
+				)
+
+				client = NewClient(provider)
+				err := client.Connect()
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should return info or an error for each handle", func() {
+				results := client.BulkInfo([]string{"a", "b", "c"}, 1)
+				Ω(results).Should(HaveLen(3))
+
+				Ω(results["a"].Err).ShouldNot(HaveOccurred())
+				Ω(results["a"].Info.GetState()).Should(Equal("active"))
+
+				Ω(results["b"].Info).Should(BeNil())
+				Ω(results["b"].Err).Should(MatchError("unknown handle: b"))
+
+				Ω(results["c"].Err).ShouldNot(HaveOccurred())
+				Ω(results["c"].Info.GetState()).Should(Equal("stopped"))
+
+				expectedWriteBufferContents := string(warden.Messages(
+					&warden.InfoRequest{Handle: proto.String("a")},
+					&warden.InfoRequest{Handle: proto.String("b")},
+					&warden.InfoRequest{Handle: proto.String("c")},
+				).Bytes())
+
+				Ω(string(writeBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
+			})
+
+			It("should return nothing for no handles", func() {
+				Ω(client.BulkInfo(nil, 10)).Should(BeEmpty())
+			})
+		})
+
+		Describe("Getting info concurrently", func() {
+			var (
+				requested chan struct{}
+				release   chan struct{}
+			)
+
+			// a connection that replies to the first request only once
+			// released, so requests can only all be in flight together if
+			// they were sent concurrently
+			blockingConn := func() net.Conn {
+				clientEnd, serverEnd := net.Pipe()
+
+				received := make(chan struct{})
+
+				go func() {
+					buf := make([]byte, 1024)
+					once := new(sync.Once)
+
+					for {
+						_, err := serverEnd.Read(buf)
+						if err != nil {
+							return
+						}
+
+						once.Do(func() { close(received) })
+					}
+				}()
+
+				go func() {
+					<-received
+					requested <- struct{}{}
+
+					<-release
+					serverEnd.Write(warden.Messages(&warden.InfoResponse{State: proto.String("active")}).Bytes())
+				}()
+
+				return clientEnd
+			}
+
+			BeforeEach(func() {
+				requested = make(chan struct{}, 3)
+				release = make(chan struct{})
+
+				providers := []ConnectionProvider{}
+				for i := 0; i < 3; i++ {
+					providers = append(providers, &FakeConnectionProvider{connection: connection.New(blockingConn())})
+				}
+
+				client = NewClient(&ManyConnectionProvider{ConnectionProviders: providers})
+			})
+
+			It("should send the requests concurrently, across connections", func() {
+				results := make(chan map[string]BulkInfoEntry)
+				go func() {
+					results <- client.BulkInfo([]string{"a", "b", "c"}, 3)
+				}()
+
+				for i := 0; i < 3; i++ {
+					Eventually(requested, 5).Should(Receive())
+				}
+
+				close(release)
+
+				var entries map[string]BulkInfoEntry
+				Eventually(results).Should(Receive(&entries))
+				Ω(entries).Should(HaveLen(3))
+
+				for _, entry := range entries {
+					Ω(entry.Err).ShouldNot(HaveOccurred())
+					Ω(entry.Info.GetState()).Should(Equal("active"))
+				}
+			})
+		})
+
+		Describe("Timing out", func() {
+			var secondWriteBuffer *bytes.Buffer
+
+			BeforeEach(func() {
+				secondWriteBuffer = bytes.NewBuffer([]byte{})
+
+				// nothing ever reads the other end of the pipe
+				clientEnd, _ := net.Pipe()
+
+				stalled := connection.New(clientEnd)
+				stalled.RequestTimeout = 100 * time.Millisecond
+
+				mcp := &ManyConnectionProvider{
+					ConnectionProviders: []ConnectionProvider{
+						&FakeConnectionProvider{connection: stalled},
+						NewFakeConnectionProvider(
+							warden.Messages(&warden.DestroyResponse{}),
+							secondWriteBuffer,
+						),
+					},
+				}
+
+				client = NewClient(mcp)
+				err := client.Connect()
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should not reuse the timed out connection", func() {
+				_, err := client.Destroy("handle a")
+				Ω(err).Should(Equal(connection.TimeoutError))
+
+				_, err = client.Destroy("handle b")
+				Ω(err).ShouldNot(HaveOccurred())
+
+				expectedWriteBufferContents := string(warden.Messages(
+					&warden.DestroyRequest{
+						Handle: proto.String("handle b"),
+					},
+				).Bytes())
+
+				Ω(string(secondWriteBuffer.Bytes())).Should(Equal(expectedWriteBufferContents))
+			})
 		})
 
 		Describe("Reconnecting", func() {
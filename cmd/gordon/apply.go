@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/provision"
+)
+
+// apply creates a container from a spec file and prints its handle,
+// followed by any mapped ports as "host -> container". Bootstrap script
+// output goes to stderr so that stdout stays easy to parse.
+func apply(client gordon.Client, args []string) error {
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	specPath := flags.String("f", "", "path to the container spec (YAML, or JSON if it ends in .json)")
+	flags.Parse(args)
+
+	if *specPath == "" {
+		return errors.New("apply: -f is required")
+	}
+
+	spec, err := provision.LoadSpec(*specPath)
+	if err != nil {
+		return err
+	}
+
+	result, err := provision.New(client, os.Stderr, os.Stderr).Apply(spec)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(result.Handle)
+
+	for _, netIn := range result.NetIn {
+		fmt.Printf("%d -> %d\n", netIn.GetHostPort(), netIn.GetContainerPort())
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"strconv"
+	"time"
+
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+	"github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/logs"
+)
+
+// logsQuietPeriod is how long logs waits for more output before detaching
+// when it isn't following. Warden replays a process's buffered output on
+// attach but can't say when it's done, so a pause is taken as the end.
+const logsQuietPeriod = 500 * time.Millisecond
+
+// logsCommand attaches to a process and prints its output line by line, stdout
+// lines to stdout and stderr lines to stderr. With -f it follows the process
+// until it exits, and fails if the exit status is non-zero.
+func logsCommand(client gordon.Client, args []string) error {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := flags.Bool("f", false, "follow output until the process exits")
+	timestamps := flags.Bool("t", false, "show the time each line was received")
+	prefix := flags.Bool("p", false, "prefix each line with the handle, process ID and source")
+	output := flags.String("o", "", "also append lines to this file")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return errors.New("logs: expected <handle> <pid>")
+	}
+
+	handle := flags.Arg(0)
+
+	processID, err := strconv.ParseUint(flags.Arg(1), 10, 32)
+	if err != nil {
+		return errors.New("logs: invalid process ID: " + flags.Arg(1))
+	}
+
+	format := logs.Format{Timestamps: *timestamps, Prefix: *prefix}
+
+	sinks := []logs.Sink{
+		logs.SplitSink(logs.WriterSink(os.Stdout, format), logs.WriterSink(os.Stderr, format)),
+	}
+
+	if *output != "" {
+		file, err := logs.OpenFileSink(*output, format)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		sinks = append(sinks, file)
+	}
+
+	stream, err := client.AttachStream(handle, uint32(processID))
+	if err != nil {
+		return err
+	}
+
+	defer stream.Detach()
+
+	lines := logs.NewStream(handle, uint32(processID), sinks...)
+
+	if *follow {
+		status, err := lines.Follow(stream.Payloads)
+		if err != nil {
+			return err
+		}
+
+		if status != 0 {
+			return &gordon.ExitStatusError{ExitStatus: status}
+		}
+
+		return nil
+	}
+
+	return printUntilQuiet(lines, stream.Payloads)
+}
+
+func printUntilQuiet(lines *logs.Stream, payloads <-chan *warden.ProcessPayload) error {
+	for {
+		select {
+		case payload, ok := <-payloads:
+			if !ok || payload.ExitStatus != nil {
+				return lines.Flush()
+			}
+
+			err := lines.Write(payload)
+			if err != nil {
+				return err
+			}
+
+		case <-time.After(logsQuietPeriod):
+			return lines.Flush()
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/cloudfoundry-incubator/gordon"
+)
+
+type command struct {
+	usage string
+	run   func(client gordon.Client, args []string) error
+}
+
+var commands = map[string]command{
+	"apply": {"apply -f <spec.yml>", apply},
+	"logs":  {"logs [-f] [-t] [-p] [-o <file>] <handle> <pid>", logsCommand},
+}
+
+var network = flag.String("network", "unix", "network used to reach warden, e.g. unix or tcp")
+var addr = flag.String("addr", "/tmp/warden.sock", "address of the warden server")
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, found := commands[flag.Arg(0)]
+	if !found {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	client := gordon.NewClient(&gordon.ConnectionInfo{
+		Network: *network,
+		Addr:    *addr,
+	})
+
+	err := client.Connect()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to connect to warden:", err)
+		os.Exit(1)
+	}
+
+	err = cmd.run(client, flag.Args()[1:])
+
+	client.Close()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [flags] <command> [args]\n\ncommands:\n", os.Args[0])
+
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+
+	fmt.Fprintln(os.Stderr, "\nflags:")
+	flag.PrintDefaults()
+}
@@ -2,28 +2,41 @@ package connection
 
 import (
 	"bufio"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"math"
 	"net"
 	"strconv"
 	"sync"
+	"time"
 
 	"code.google.com/p/gogoprotobuf/proto"
 	warden "github.com/cloudfoundry-incubator/garden/protocol"
 )
 
 var DisconnectedError = errors.New("disconnected")
+var TimeoutError = errors.New("timed out waiting for warden")
 
 type Connection struct {
 	Disconnected chan bool
 
+	// applied to every RoundTrip; zero means wait forever
+	RequestTimeout time.Duration
+
 	messages chan *warden.Message
 
 	conn      net.Conn
 	read      *bufio.Reader
 	writeLock sync.Mutex
 	readLock  sync.Mutex
+
+	dead     bool
+	timedOut bool
+	deadLock sync.RWMutex
+
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 type WardenError struct {
@@ -37,7 +50,15 @@ func (e *WardenError) Error() string {
 }
 
 func Connect(network, addr string) (*Connection, error) {
-	conn, err := net.Dial(network, addr)
+	return Dial(&net.Dialer{}, network, addr)
+}
+
+func ConnectTLS(network, addr string, config *tls.Config) (*Connection, error) {
+	return DialTLS(&net.Dialer{}, network, addr, config)
+}
+
+func Dial(dialer *net.Dialer, network, addr string) (*Connection, error) {
+	conn, err := dialer.Dial(network, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -45,6 +66,33 @@ func Connect(network, addr string) (*Connection, error) {
 	return New(conn), nil
 }
 
+func DialTLS(dialer *net.Dialer, network, addr string, config *tls.Config) (*Connection, error) {
+	conn, err := dialer.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, config)
+
+	// the dial timeout also bounds the handshake, so that a server that
+	// accepts but never responds doesn't hang us
+	if dialer.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(dialer.Timeout))
+	}
+
+	// handshake eagerly so that certificate problems surface here
+	// rather than on the first request
+	err = tlsConn.Handshake()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return New(tlsConn), nil
+}
+
 func New(conn net.Conn) *Connection {
 	messages := make(chan *warden.Message)
 
@@ -55,6 +103,8 @@ func New(conn net.Conn) *Connection {
 
 		messages: messages,
 
+		closed: make(chan struct{}),
+
 		conn: conn,
 		read: bufio.NewReader(conn),
 	}
@@ -65,6 +115,14 @@ func New(conn net.Conn) *Connection {
 }
 
 func (c *Connection) Close() {
+	c.closeOnce.Do(func() {
+		c.deadLock.Lock()
+		c.dead = true
+		c.deadLock.Unlock()
+
+		close(c.closed)
+	})
+
 	c.conn.Close()
 }
 
@@ -118,7 +176,7 @@ func (c *Connection) Destroy(handle string) (*warden.DestroyResponse, error) {
 }
 
 func (c *Connection) Run(handle, script string, resourceLimits *warden.ResourceLimits, environmentVariables []*warden.EnvironmentVariable) (uint32, chan *warden.ProcessPayload, error) {
-	err := c.SendMessage(
+	return c.RunProcess(
 		&warden.RunRequest{
 			Handle:  proto.String(handle),
 			Script:  proto.String(script),
@@ -126,6 +184,12 @@ func (c *Connection) Run(handle, script string, resourceLimits *warden.ResourceL
 			Env:     environmentVariables,
 		},
 	)
+}
+
+// RunProcess is Run for callers that need the rest of RunRequest, such as
+// Privileged.
+func (c *Connection) RunProcess(request *warden.RunRequest) (uint32, chan *warden.ProcessPayload, error) {
+	err := c.SendMessage(request)
 
 	if err != nil {
 		return 0, nil, err
@@ -140,24 +204,7 @@ func (c *Connection) Run(handle, script string, resourceLimits *warden.ResourceL
 
 	firstResponse := resMsg.(*warden.ProcessPayload)
 
-	go func() {
-		for {
-			resMsg, err := c.ReadResponse(&warden.ProcessPayload{})
-			if err != nil {
-				close(responses)
-				break
-			}
-
-			response := resMsg.(*warden.ProcessPayload)
-
-			responses <- response
-
-			if response.ExitStatus != nil {
-				close(responses)
-				break
-			}
-		}
-	}()
+	go c.streamPayloads(responses)
 
 	return firstResponse.GetProcessId(), responses, nil
 }
@@ -176,33 +223,83 @@ func (c *Connection) Attach(handle string, processID uint32) (chan *warden.Proce
 
 	responses := make(chan *warden.ProcessPayload)
 
-	go func() {
-		for {
-			resMsg, err := c.ReadResponse(&warden.ProcessPayload{})
-			if err != nil {
-				close(responses)
-				break
-			}
+	go c.streamPayloads(responses)
 
-			response := resMsg.(*warden.ProcessPayload)
+	return responses, nil
+}
 
-			responses <- response
+// SendStdin writes data to the stdin of a process started on this
+// connection with Run.
+func (c *Connection) SendStdin(processID uint32, data []byte) error {
+	source := warden.ProcessPayload_stdin
 
-			if response.ExitStatus != nil {
-				close(responses)
-				break
-			}
+	return c.SendMessage(
+		&warden.ProcessPayload{
+			ProcessId: proto.Uint32(processID),
+			Source:    &source,
+			Data:      proto.String(string(data)),
+		},
+	)
+}
+
+// CloseStdin sends a stdin payload without data, which closes the
+// process's stdin.
+func (c *Connection) CloseStdin(processID uint32) error {
+	source := warden.ProcessPayload_stdin
+
+	return c.SendMessage(
+		&warden.ProcessPayload{
+			ProcessId: proto.Uint32(processID),
+			Source:    &source,
+		},
+	)
+}
+
+// streamPayloads forwards process payloads until the process exits, the
+// connection fails, or the connection is closed, so that a consumer that
+// stops reading can't leave it blocked forever
+func (c *Connection) streamPayloads(responses chan<- *warden.ProcessPayload) {
+	defer close(responses)
+
+	for {
+		resMsg, err := c.ReadResponse(&warden.ProcessPayload{})
+		if err != nil {
+			return
 		}
-	}()
 
-	return responses, nil
+		response := resMsg.(*warden.ProcessPayload)
+
+		select {
+		case responses <- response:
+		case <-c.closed:
+			return
+		}
+
+		if response.ExitStatus != nil {
+			return
+		}
+	}
 }
 
 func (c *Connection) NetIn(handle string) (*warden.NetInResponse, error) {
-	res, err := c.RoundTrip(
-		&warden.NetInRequest{Handle: proto.String(handle)},
-		&warden.NetInResponse{},
-	)
+	return c.NetInWithPorts(handle, 0, 0)
+}
+
+// NetInWithPorts maps hostPort to containerPort. Warden picks the host port
+// if it's zero, and uses the host port inside the container if
+// containerPort is.
+func (c *Connection) NetInWithPorts(handle string, hostPort, containerPort uint32) (*warden.NetInResponse, error) {
+	req := &warden.NetInRequest{Handle: proto.String(handle)}
+
+	if hostPort != 0 {
+		req.HostPort = proto.Uint32(hostPort)
+	}
+
+	if containerPort != 0 {
+		req.ContainerPort = proto.Uint32(containerPort)
+	}
+
+	res, err := c.RoundTrip(req, &warden.NetInResponse{})
 
 	if err != nil {
 		return nil, err
@@ -354,12 +451,19 @@ func (c *Connection) Info(handle string) (*warden.InfoResponse, error) {
 }
 
 func (c *Connection) RoundTrip(request proto.Message, response proto.Message) (proto.Message, error) {
-	err := c.SendMessage(request)
+	return c.RoundTripWithTimeout(request, response, c.RequestTimeout)
+}
+
+// RoundTripWithTimeout bounds both writing the request and reading the
+// response by timeout. If either times out, TimeoutError is returned and the
+// connection is closed, as it can no longer be used safely.
+func (c *Connection) RoundTripWithTimeout(request proto.Message, response proto.Message, timeout time.Duration) (proto.Message, error) {
+	err := c.sendMessage(request, timeout)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.ReadResponse(response)
+	resp, err := c.readResponse(response, timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -368,6 +472,10 @@ func (c *Connection) RoundTrip(request proto.Message, response proto.Message) (p
 }
 
 func (c *Connection) SendMessage(req proto.Message) error {
+	return c.sendMessage(req, 0)
+}
+
+func (c *Connection) sendMessage(req proto.Message, timeout time.Duration) error {
 	c.writeLock.Lock()
 	defer c.writeLock.Unlock()
 
@@ -386,6 +494,11 @@ func (c *Connection) SendMessage(req proto.Message) error {
 		return err
 	}
 
+	if timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(timeout))
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+
 	_, err = c.conn.Write(
 		[]byte(
 			fmt.Sprintf(
@@ -397,6 +510,11 @@ func (c *Connection) SendMessage(req proto.Message) error {
 	)
 
 	if err != nil {
+		if isTimeout(err) {
+			c.timeOut()
+			return TimeoutError
+		}
+
 		c.disconnected()
 		return err
 	}
@@ -408,7 +526,12 @@ func (c *Connection) readMessages() {
 	for {
 		payload, err := c.readPayload()
 		if err != nil {
-			c.disconnected()
+			if isTimeout(err) {
+				c.timeOut()
+			} else {
+				c.disconnected()
+			}
+
 			close(c.messages)
 			break
 		}
@@ -419,17 +542,77 @@ func (c *Connection) readMessages() {
 			continue
 		}
 
-		c.messages <- message
+		select {
+		case c.messages <- message:
+		case <-c.closed:
+			close(c.messages)
+			return
+		}
 	}
 }
 
 func (c *Connection) disconnected() {
-	c.Disconnected <- true
+	c.deadLock.Lock()
+	c.dead = true
+	c.deadLock.Unlock()
+
+	// non-blocking; any number of errors may notice the disconnect, but
+	// consumers only need to hear about it once
+	select {
+	case c.Disconnected <- true:
+	default:
+	}
+}
+
+// a partially written request or partially read response leaves the
+// stream in an unknown state, so the connection is given up entirely
+func (c *Connection) timeOut() {
+	c.deadLock.Lock()
+	c.timedOut = true
+	c.deadLock.Unlock()
+
+	c.disconnected()
+	c.conn.Close()
+}
+
+func (c *Connection) hasTimedOut() bool {
+	c.deadLock.RLock()
+	defer c.deadLock.RUnlock()
+
+	return c.timedOut
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// Alive reports whether the connection has not yet seen a read or write
+// error. Idle connections whose peer went away (detected via EOF or TCP
+// keepalive) report false.
+func (c *Connection) Alive() bool {
+	c.deadLock.RLock()
+	defer c.deadLock.RUnlock()
+
+	return !c.dead
 }
 
 func (c *Connection) ReadResponse(response proto.Message) (proto.Message, error) {
+	return c.readResponse(response, 0)
+}
+
+func (c *Connection) readResponse(response proto.Message, timeout time.Duration) (proto.Message, error) {
+	if timeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(timeout))
+		defer c.conn.SetReadDeadline(time.Time{})
+	}
+
 	message, ok := <-c.messages
 	if !ok {
+		if c.hasTimedOut() {
+			return nil, TimeoutError
+		}
+
 		return nil, DisconnectedError
 	}
 
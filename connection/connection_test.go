@@ -2,7 +2,10 @@ package connection_test
 
 import (
 	"bytes"
+	"io"
+	"io/ioutil"
 	"math"
+	"net"
 	"time"
 	. "github.com/cloudfoundry-incubator/gordon/connection"
 	. "github.com/onsi/ginkgo"
@@ -250,6 +253,30 @@ var _ = Describe("Connection", func() {
 				Handle: proto.String("foo-handle"),
 			})
 		})
+
+		It("should send the requested ports, leaving unset ones to warden", func() {
+			_, err := connection.NetInWithPorts("foo-handle", 0, 7332)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			assertWriteBufferContains(&warden.NetInRequest{
+				Handle:        proto.String("foo-handle"),
+				ContainerPort: proto.Uint32(7332),
+			})
+		})
+	})
+
+	Describe("Sending stdin", func() {
+		It("should send stdin payloads, and an empty one to close it", func() {
+			Ω(connection.SendStdin(42, []byte("some data"))).Should(Succeed())
+			Ω(connection.CloseStdin(42)).Should(Succeed())
+
+			stdin := warden.ProcessPayload_stdin
+
+			assertWriteBufferContains(
+				&warden.ProcessPayload{ProcessId: proto.Uint32(42), Source: &stdin, Data: proto.String("some data")},
+				&warden.ProcessPayload{ProcessId: proto.Uint32(42), Source: &stdin},
+			)
+		})
 	})
 
 	Describe("Listing containers", func() {
@@ -354,6 +381,15 @@ var _ = Describe("Connection", func() {
 			<-connection.Disconnected
 			close(done)
 		})
+
+		It("should no longer be alive", func() {
+			Ω(connection.Alive()).Should(BeTrue())
+
+			_, err := connection.Destroy("foo-handle")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Eventually(connection.Alive).Should(BeFalse())
+		})
 	})
 
 	Describe("Disconnecting", func() {
@@ -389,6 +425,85 @@ var _ = Describe("Connection", func() {
 		})
 	})
 
+	Describe("Round tripping with a timeout", func() {
+		var (
+			clientEnd net.Conn
+			serverEnd net.Conn
+		)
+
+		BeforeEach(func() {
+			clientEnd, serverEnd = net.Pipe()
+		})
+
+		JustBeforeEach(func() {
+			connection = New(clientEnd)
+		})
+
+		AfterEach(func() {
+			serverEnd.Close()
+		})
+
+		Context("when the response arrives in time", func() {
+			BeforeEach(func() {
+				go func() {
+					buf := make([]byte, 1024)
+					serverEnd.Read(buf)
+					serverEnd.Write(warden.Messages(&warden.EchoResponse{Message: proto.String("pong")}).Bytes())
+				}()
+			})
+
+			It("should do the round trip and keep the connection usable", func() {
+				resp, err := connection.RoundTripWithTimeout(
+					&warden.EchoRequest{Message: proto.String("ping")},
+					&warden.EchoResponse{},
+					time.Second,
+				)
+
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(resp.(*warden.EchoResponse).GetMessage()).Should(Equal("pong"))
+
+				Consistently(connection.Alive, 200*time.Millisecond).Should(BeTrue())
+			})
+		})
+
+		Context("when the server never reads the request", func() {
+			It("should time out the write", func() {
+				_, err := connection.RoundTripWithTimeout(
+					&warden.EchoRequest{Message: proto.String("ping")},
+					&warden.EchoResponse{},
+					100*time.Millisecond,
+				)
+
+				Ω(err).Should(Equal(TimeoutError))
+				Ω(connection.Alive()).Should(BeFalse())
+			})
+		})
+
+		Context("when the server never responds", func() {
+			BeforeEach(func() {
+				go io.Copy(ioutil.Discard, serverEnd)
+			})
+
+			It("should time out the read", func() {
+				_, err := connection.RoundTripWithTimeout(
+					&warden.EchoRequest{Message: proto.String("ping")},
+					&warden.EchoResponse{},
+					100*time.Millisecond,
+				)
+
+				Ω(err).Should(Equal(TimeoutError))
+				Ω(connection.Alive()).Should(BeFalse())
+			})
+
+			It("should apply the connection's request timeout to every round trip", func() {
+				connection.RequestTimeout = 100 * time.Millisecond
+
+				_, err := connection.Destroy("foo-handle")
+				Ω(err).Should(Equal(TimeoutError))
+			})
+		})
+	})
+
 	Describe("Running", func() {
 		stdout := warden.ProcessPayload_stdout
 		stderr := warden.ProcessPayload_stderr
@@ -471,6 +586,35 @@ var _ = Describe("Connection", func() {
 		})
 	})
 
+	Describe("Closing while a stream is unread", func() {
+		JustBeforeEach(func() {
+			stdout := warden.ProcessPayload_stdout
+
+			connection = New(NewPipeConn(warden.Messages(
+				&warden.ProcessPayload{ProcessId: proto.Uint32(42)},
+				&warden.ProcessPayload{ProcessId: proto.Uint32(42), Source: &stdout, Data: proto.String("1")},
+				&warden.ProcessPayload{ProcessId: proto.Uint32(42), Source: &stdout, Data: proto.String("2")},
+			)))
+		})
+
+		It("should not leak the streaming goroutine", func() {
+			streams := NewGoroutines("(*Connection).Run")
+
+			_, _, err := connection.Run("foo-handle", "lol", resourceLimits, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(streams()).Should(HaveLen(1))
+
+			// let the stream block delivering output that nobody reads
+			time.Sleep(100 * time.Millisecond)
+
+			connection.Close()
+
+			Ω(connection.Alive()).Should(BeFalse())
+			Eventually(streams).Should(BeEmpty())
+		})
+	})
+
 	Describe("Attaching", func() {
 
 		stdout := warden.ProcessPayload_stdout
@@ -1,6 +1,10 @@
 package gordon
 
 import (
+	"net"
+	"strings"
+	"time"
+
 	"github.com/cloudfoundry-incubator/gordon/connection"
 )
 
@@ -11,8 +15,79 @@ type ConnectionProvider interface {
 type ConnectionInfo struct {
 	Network string
 	Addr    string
+
+	// if set, the connection is wrapped in TLS before use
+	TLS *TLSConfig
+
+	// bounds connecting, including the TLS handshake; zero means no timeout
+	DialTimeout time.Duration
+
+	// period between TCP keepalive probes; zero disables them
+	KeepAlive time.Duration
+
+	// local address to dial from, e.g. "10.0.0.2:0"
+	LocalAddr string
+
+	// bounds each request/response round trip; zero means wait forever
+	RequestTimeout time.Duration
 }
 
 func (i *ConnectionInfo) ProvideConnection() (*connection.Connection, error) {
-	return connection.Connect(i.Network, i.Addr)
+	conn, err := i.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	conn.RequestTimeout = i.RequestTimeout
+
+	return conn, nil
+}
+
+func (i *ConnectionInfo) dial() (*connection.Connection, error) {
+	dialer, err := i.dialer()
+	if err != nil {
+		return nil, err
+	}
+
+	if i.TLS == nil {
+		return connection.Dial(dialer, i.Network, i.Addr)
+	}
+
+	config, err := i.TLS.clientConfig(i.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return connection.DialTLS(dialer, i.Network, i.Addr, config)
+}
+
+func (i *ConnectionInfo) dialer() (*net.Dialer, error) {
+	dialer := &net.Dialer{
+		Timeout:   i.DialTimeout,
+		KeepAlive: i.KeepAlive,
+	}
+
+	if i.KeepAlive == 0 {
+		// net.Dialer enables keepalive by default on newer Go versions
+		dialer.KeepAlive = -1
+	}
+
+	if i.LocalAddr != "" {
+		localAddr, err := resolveAddr(i.Network, i.LocalAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		dialer.LocalAddr = localAddr
+	}
+
+	return dialer, nil
+}
+
+func resolveAddr(network, addr string) (net.Addr, error) {
+	if strings.HasPrefix(network, "unix") {
+		return net.ResolveUnixAddr(network, addr)
+	}
+
+	return net.ResolveTCPAddr(network, addr)
 }
@@ -1,10 +1,17 @@
 package gordon_test
 
 import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
 	. "github.com/cloudfoundry-incubator/gordon"
+	. "github.com/cloudfoundry-incubator/gordon/test_helpers"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"net"
 )
 
 var _ = Describe("ConnectionProvider", func() {
@@ -27,4 +34,236 @@ var _ = Describe("ConnectionProvider", func() {
 		Ω(conn).ShouldNot(BeNil())
 		Ω(err).ShouldNot(HaveOccurred())
 	})
+
+	It("should dial from the given local address", func() {
+		// find a port that's free to dial from
+		free, err := net.Listen("tcp", "127.0.0.1:0")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		localPort := free.Addr().(*net.TCPAddr).Port
+		free.Close()
+
+		info := &ConnectionInfo{
+			Network:   "tcp",
+			Addr:      listener.Addr().String(),
+			LocalAddr: fmt.Sprintf("127.0.0.1:%d", localPort),
+		}
+
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err == nil {
+				accepted <- conn
+			}
+		}()
+
+		_, err = info.ProvideConnection()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var serverSide net.Conn
+		Eventually(accepted).Should(Receive(&serverSide))
+		Ω(serverSide.RemoteAddr().(*net.TCPAddr).Port).Should(Equal(localPort))
+	})
+
+	Describe("keepalive", func() {
+		// dials the listener and returns the server's side of the connection
+		connect := func(info *ConnectionInfo) net.Conn {
+			accepted := make(chan net.Conn, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err == nil {
+					accepted <- conn
+				}
+			}()
+
+			_, err := info.ProvideConnection()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var serverSide net.Conn
+			Eventually(accepted).Should(Receive(&serverSide))
+
+			return serverSide
+		}
+
+		It("should be enabled when a period is given", func() {
+			serverSide := connect(&ConnectionInfo{
+				Network:   "tcp",
+				Addr:      listener.Addr().String(),
+				KeepAlive: 30 * time.Second,
+			})
+
+			Ω(keepAliveEnabled(serverSide.RemoteAddr())).Should(BeTrue())
+		})
+
+		It("should be disabled when no period is given", func() {
+			serverSide := connect(&ConnectionInfo{
+				Network: "tcp",
+				Addr:    listener.Addr().String(),
+			})
+
+			Ω(keepAliveEnabled(serverSide.RemoteAddr())).Should(BeFalse())
+		})
+	})
+
+	It("should fail when the local address is invalid", func() {
+		info := &ConnectionInfo{
+			Network:   "tcp",
+			Addr:      listener.Addr().String(),
+			LocalAddr: "not an address",
+		}
+
+		_, err := info.ProvideConnection()
+		Ω(err).Should(HaveOccurred())
+	})
+
+	Context("when the server accepts but never responds", func() {
+		It("should give up on the TLS handshake after the dial timeout", func(done Done) {
+			info := &ConnectionInfo{
+				Network:     listener.Addr().Network(),
+				Addr:        listener.Addr().String(),
+				TLS:         &TLSConfig{ServerName: "localhost"},
+				DialTimeout: 100 * time.Millisecond,
+			}
+
+			_, err := info.ProvideConnection()
+			Ω(err).Should(HaveOccurred())
+
+			close(done)
+		}, 5)
+	})
+
+	Describe("over TLS", func() {
+		var (
+			certDir string
+			certs   *TLSCertificates
+			server  *TLSServer
+
+			requireClientCert bool
+		)
+
+		BeforeEach(func() {
+			var err error
+
+			requireClientCert = false
+
+			certDir, err = ioutil.TempDir("", "gordon-tls")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			certs, err = GenerateTLSCertificates(certDir)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		JustBeforeEach(func() {
+			config, err := certs.ServerConfig(requireClientCert)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			server, err = NewTLSServer(config)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			server.Close()
+			os.RemoveAll(certDir)
+		})
+
+		It("should connect when the server is signed by the CA", func() {
+			info := &ConnectionInfo{
+				Network: "tcp",
+				Addr:    server.Addr(),
+				TLS: &TLSConfig{
+					CACertFile: certs.CACertFile,
+					MinVersion: tls.VersionTLS12,
+				},
+			}
+
+			conn, err := info.ProvideConnection()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(conn).ShouldNot(BeNil())
+
+			Ω(<-server.Handshakes).ShouldNot(HaveOccurred())
+		})
+
+		It("should fail when the server name does not match", func() {
+			info := &ConnectionInfo{
+				Network: "tcp",
+				Addr:    server.Addr(),
+				TLS: &TLSConfig{
+					CACertFile: certs.CACertFile,
+					ServerName: "warden.example.com",
+				},
+			}
+
+			_, err := info.ProvideConnection()
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should fail when the server is not signed by a trusted CA", func() {
+			otherDir, err := ioutil.TempDir("", "gordon-tls-other")
+			Ω(err).ShouldNot(HaveOccurred())
+			defer os.RemoveAll(otherDir)
+
+			otherCerts, err := GenerateTLSCertificates(otherDir)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			info := &ConnectionInfo{
+				Network: "tcp",
+				Addr:    server.Addr(),
+				TLS:     &TLSConfig{CACertFile: otherCerts.CACertFile},
+			}
+
+			_, err = info.ProvideConnection()
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should fail when the CA bundle has no certificates", func() {
+			bogusBundle := certDir + "/bogus.crt"
+			err := ioutil.WriteFile(bogusBundle, []byte("not a certificate"), 0600)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			info := &ConnectionInfo{
+				Network: "tcp",
+				Addr:    server.Addr(),
+				TLS:     &TLSConfig{CACertFile: bogusBundle},
+			}
+
+			_, err = info.ProvideConnection()
+			Ω(err).Should(Equal(ErrInvalidCABundle))
+		})
+
+		Context("when the server requires a client certificate", func() {
+			BeforeEach(func() {
+				requireClientCert = true
+			})
+
+			It("should connect when presenting the client certificate", func() {
+				info := &ConnectionInfo{
+					Network: "tcp",
+					Addr:    server.Addr(),
+					TLS: &TLSConfig{
+						CACertFile: certs.CACertFile,
+						CertFile:   certs.ClientCertFile,
+						KeyFile:    certs.ClientKeyFile,
+					},
+				}
+
+				conn, err := info.ProvideConnection()
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(conn).ShouldNot(BeNil())
+
+				Ω(<-server.Handshakes).ShouldNot(HaveOccurred())
+			})
+
+			It("should be rejected without a client certificate", func() {
+				info := &ConnectionInfo{
+					Network: "tcp",
+					Addr:    server.Addr(),
+					TLS:     &TLSConfig{CACertFile: certs.CACertFile},
+				}
+
+				info.ProvideConnection()
+
+				Ω(<-server.Handshakes).Should(HaveOccurred())
+			})
+		})
+	})
 })
@@ -0,0 +1,166 @@
+package gordon
+
+import (
+	"io"
+	"sync"
+
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+// Container binds a handle to the client that manages it, so that callers
+// don't have to pass the handle to every request.
+type Container struct {
+	client Client
+	handle string
+
+	properties     map[string]string
+	propertiesLock sync.Mutex
+}
+
+func CreateContainer(client Client, properties map[string]string) (*Container, error) {
+	res, err := client.Create(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	container := NewContainer(client, res.GetHandle())
+
+	container.properties = map[string]string{}
+	for key, val := range properties {
+		container.properties[key] = val
+	}
+
+	return container, nil
+}
+
+// LookupContainer returns the container with the given handle, failing if
+// warden doesn't know about it.
+func LookupContainer(client Client, handle string) (*Container, error) {
+	container := NewContainer(client, handle)
+
+	_, err := container.Properties()
+	if err != nil {
+		return nil, err
+	}
+
+	return container, nil
+}
+
+func ListContainers(client Client, filterProperties map[string]string) ([]*Container, error) {
+	res, err := client.List(filterProperties)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := []*Container{}
+	for _, handle := range res.GetHandles() {
+		containers = append(containers, NewContainer(client, handle))
+	}
+
+	return containers, nil
+}
+
+// NewContainer wraps a handle without talking to warden.
+func NewContainer(client Client, handle string) *Container {
+	return &Container{
+		client: client,
+		handle: handle,
+	}
+}
+
+func (c *Container) Handle() string {
+	return c.handle
+}
+
+func (c *Container) Stop(background, kill bool) (*warden.StopResponse, error) {
+	return c.client.Stop(c.handle, background, kill)
+}
+
+func (c *Container) Destroy() (*warden.DestroyResponse, error) {
+	return c.client.Destroy(c.handle)
+}
+
+func (c *Container) Run(script string, resourceLimits ResourceLimits, environmentVariables []EnvironmentVariable) (uint32, <-chan *warden.ProcessPayload, error) {
+	return c.client.Run(c.handle, script, resourceLimits, environmentVariables)
+}
+
+func (c *Container) Exec(argv []string, environmentVariables []EnvironmentVariable, resourceLimits ResourceLimits) (uint32, <-chan *warden.ProcessPayload, error) {
+	return c.client.Exec(c.handle, argv, environmentVariables, resourceLimits)
+}
+
+func (c *Container) RunWithSpec(spec RunSpec) (*ProcessStream, error) {
+	return c.client.RunWithSpec(c.handle, spec)
+}
+
+func (c *Container) Attach(processID uint32) (<-chan *warden.ProcessPayload, error) {
+	return c.client.Attach(c.handle, processID)
+}
+
+func (c *Container) Info() (*warden.InfoResponse, error) {
+	return c.client.Info(c.handle)
+}
+
+func (c *Container) NetIn() (*warden.NetInResponse, error) {
+	return c.client.NetIn(c.handle)
+}
+
+func (c *Container) NetInWithPorts(hostPort, containerPort uint32) (*warden.NetInResponse, error) {
+	return c.client.NetInWithPorts(c.handle, hostPort, containerPort)
+}
+
+func (c *Container) CopyIn(src, dst string) (*warden.CopyInResponse, error) {
+	return c.client.CopyIn(c.handle, src, dst)
+}
+
+func (c *Container) CopyOut(src, dst, owner string) (*warden.CopyOutResponse, error) {
+	return c.client.CopyOut(c.handle, src, dst, owner)
+}
+
+func (c *Container) StreamIn(dst string, r io.Reader) error {
+	return c.client.StreamIn(c.handle, dst, r)
+}
+
+func (c *Container) StreamOut(src string) (io.ReadCloser, error) {
+	return c.client.StreamOut(c.handle, src)
+}
+
+func (c *Container) LimitMemory(limit uint64) (*warden.LimitMemoryResponse, error) {
+	return c.client.LimitMemory(c.handle, limit)
+}
+
+func (c *Container) LimitCPU(limitInShares uint64) (*warden.LimitCpuResponse, error) {
+	return c.client.LimitCPU(c.handle, limitInShares)
+}
+
+func (c *Container) LimitDisk(limits DiskLimits) (*warden.LimitDiskResponse, error) {
+	return c.client.LimitDisk(c.handle, limits)
+}
+
+// Properties returns the properties the container was created with. They
+// are fetched with Info the first time if the container wasn't created
+// through CreateContainer.
+func (c *Container) Properties() (map[string]string, error) {
+	c.propertiesLock.Lock()
+	defer c.propertiesLock.Unlock()
+
+	if c.properties != nil {
+		return c.properties, nil
+	}
+
+	info, err := c.client.GetInfo(c.handle)
+	if err != nil {
+		return nil, err
+	}
+
+	c.properties = info.Properties
+
+	return info.Properties, nil
+}
+
+func (c *Container) Signal(processID uint32, signal string) error {
+	return c.client.Signal(c.handle, processID, signal)
+}
+
+func (c *Container) StartProcess(spec RunSpec) (*Process, error) {
+	return StartProcess(c.client, c.handle, spec)
+}
@@ -0,0 +1,142 @@
+package gordon
+
+import (
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+// ContainerInfo is InfoResponse without the protocol's pointer fields.
+type ContainerInfo struct {
+	State  string
+	Events []string
+
+	HostIP        string
+	ContainerIP   string
+	ContainerPath string
+
+	ProcessIDs []uint64
+
+	MemoryStat    MemoryStat
+	CPUStat       CPUStat
+	DiskStat      DiskStat
+	BandwidthStat BandwidthStat
+
+	Properties  map[string]string
+	MappedPorts []PortMapping
+}
+
+type MemoryStat struct {
+	Cache                   uint64
+	Rss                     uint64
+	MappedFile              uint64
+	Pgpgin                  uint64
+	Pgpgout                 uint64
+	Swap                    uint64
+	Pgfault                 uint64
+	Pgmajfault              uint64
+	InactiveAnon            uint64
+	ActiveAnon              uint64
+	InactiveFile            uint64
+	ActiveFile              uint64
+	Unevictable             uint64
+	HierarchicalMemoryLimit uint64
+	HierarchicalMemswLimit  uint64
+	TotalCache              uint64
+	TotalRss                uint64
+	TotalSwap               uint64
+}
+
+type CPUStat struct {
+	Usage  uint64
+	User   uint64
+	System uint64
+}
+
+type DiskStat struct {
+	BytesUsed  uint64
+	InodesUsed uint64
+}
+
+type BandwidthStat struct {
+	InRate   uint64
+	InBurst  uint64
+	OutRate  uint64
+	OutBurst uint64
+}
+
+type PortMapping struct {
+	HostPort      uint32
+	ContainerPort uint32
+}
+
+func NewContainerInfo(res *warden.InfoResponse) ContainerInfo {
+	memory := res.GetMemoryStat()
+	cpu := res.GetCpuStat()
+	disk := res.GetDiskStat()
+	bandwidth := res.GetBandwidthStat()
+
+	info := ContainerInfo{
+		State:  res.GetState(),
+		Events: res.GetEvents(),
+
+		HostIP:        res.GetHostIp(),
+		ContainerIP:   res.GetContainerIp(),
+		ContainerPath: res.GetContainerPath(),
+
+		ProcessIDs: res.GetProcessIds(),
+
+		MemoryStat: MemoryStat{
+			Cache:                   memory.GetCache(),
+			Rss:                     memory.GetRss(),
+			MappedFile:              memory.GetMappedFile(),
+			Pgpgin:                  memory.GetPgpgin(),
+			Pgpgout:                 memory.GetPgpgout(),
+			Swap:                    memory.GetSwap(),
+			Pgfault:                 memory.GetPgfault(),
+			Pgmajfault:              memory.GetPgmajfault(),
+			InactiveAnon:            memory.GetInactiveAnon(),
+			ActiveAnon:              memory.GetActiveAnon(),
+			InactiveFile:            memory.GetInactiveFile(),
+			ActiveFile:              memory.GetActiveFile(),
+			Unevictable:             memory.GetUnevictable(),
+			HierarchicalMemoryLimit: memory.GetHierarchicalMemoryLimit(),
+			HierarchicalMemswLimit:  memory.GetHierarchicalMemswLimit(),
+			TotalCache:              memory.GetTotalCache(),
+			TotalRss:                memory.GetTotalRss(),
+			TotalSwap:               memory.GetTotalSwap(),
+		},
+
+		CPUStat: CPUStat{
+			Usage:  cpu.GetUsage(),
+			User:   cpu.GetUser(),
+			System: cpu.GetSystem(),
+		},
+
+		DiskStat: DiskStat{
+			BytesUsed:  disk.GetBytesUsed(),
+			InodesUsed: disk.GetInodesUsed(),
+		},
+
+		BandwidthStat: BandwidthStat{
+			InRate:   bandwidth.GetInRate(),
+			InBurst:  bandwidth.GetInBurst(),
+			OutRate:  bandwidth.GetOutRate(),
+			OutBurst: bandwidth.GetOutBurst(),
+		},
+
+		Properties:  map[string]string{},
+		MappedPorts: []PortMapping{},
+	}
+
+	for _, property := range res.GetProperties() {
+		info.Properties[property.GetKey()] = property.GetValue()
+	}
+
+	for _, mapping := range res.GetMappedPorts() {
+		info.MappedPorts = append(info.MappedPorts, PortMapping{
+			HostPort:      mapping.GetHostPort(),
+			ContainerPort: mapping.GetContainerPort(),
+		})
+	}
+
+	return info
+}
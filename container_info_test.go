@@ -0,0 +1,67 @@
+package gordon_test
+
+import (
+	. "github.com/cloudfoundry-incubator/gordon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("ContainerInfo", func() {
+	It("should convert every field of the response", func() {
+		info := NewContainerInfo(&warden.InfoResponse{
+			State:         proto.String("active"),
+			Events:        []string{"out of memory"},
+			HostIp:        proto.String("10.0.0.1"),
+			ContainerIp:   proto.String("10.0.0.2"),
+			ContainerPath: proto.String("/containers/abc"),
+			ProcessIds:    []uint64{1, 2},
+			MemoryStat: &warden.InfoResponse_MemoryStat{
+				Rss:                     proto.Uint64(100),
+				HierarchicalMemoryLimit: proto.Uint64(1024),
+			},
+			CpuStat: &warden.InfoResponse_CpuStat{
+				Usage: proto.Uint64(5),
+			},
+			DiskStat: &warden.InfoResponse_DiskStat{
+				BytesUsed:  proto.Uint64(2048),
+				InodesUsed: proto.Uint64(10),
+			},
+			BandwidthStat: &warden.InfoResponse_BandwidthStat{
+				InRate:  proto.Uint64(1),
+				OutRate: proto.Uint64(2),
+			},
+			Properties: []*warden.Property{
+				{Key: proto.String("owner"), Value: proto.String("executor")},
+			},
+			MappedPorts: []*warden.InfoResponse_PortMapping{
+				{HostPort: proto.Uint32(61000), ContainerPort: proto.Uint32(8080)},
+			},
+		})
+
+		Ω(info).Should(Equal(ContainerInfo{
+			State:         "active",
+			Events:        []string{"out of memory"},
+			HostIP:        "10.0.0.1",
+			ContainerIP:   "10.0.0.2",
+			ContainerPath: "/containers/abc",
+			ProcessIDs:    []uint64{1, 2},
+			MemoryStat:    MemoryStat{Rss: 100, HierarchicalMemoryLimit: 1024},
+			CPUStat:       CPUStat{Usage: 5},
+			DiskStat:      DiskStat{BytesUsed: 2048, InodesUsed: 10},
+			BandwidthStat: BandwidthStat{InRate: 1, OutRate: 2},
+			Properties:    map[string]string{"owner": "executor"},
+			MappedPorts:   []PortMapping{{HostPort: 61000, ContainerPort: 8080}},
+		}))
+	})
+
+	It("should zero missing stats", func() {
+		info := NewContainerInfo(&warden.InfoResponse{})
+
+		Ω(info.MemoryStat).Should(BeZero())
+		Ω(info.Properties).Should(BeEmpty())
+		Ω(info.MappedPorts).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,140 @@
+package gordon_test
+
+import (
+	"errors"
+
+	. "github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("Container", func() {
+	var fakeGordon *fake_gordon.FakeGordon
+
+	BeforeEach(func() {
+		fakeGordon = fake_gordon.New()
+	})
+
+	Describe("CreateContainer", func() {
+		It("should create a container with the given properties", func() {
+			container, err := CreateContainer(fakeGordon, map[string]string{"owner": "executor"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(fakeGordon.CreatedHandles()).Should(Equal([]string{container.Handle()}))
+			Ω(fakeGordon.CreatedProperties(container.Handle())).Should(Equal(map[string]string{"owner": "executor"}))
+
+			properties, err := container.Properties()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(properties).Should(Equal(map[string]string{"owner": "executor"}))
+		})
+
+		It("should return errors from creating", func() {
+			fakeGordon.CreateError = errors.New("oh no")
+
+			_, err := CreateContainer(fakeGordon, nil)
+			Ω(err).Should(Equal(errors.New("oh no")))
+		})
+	})
+
+	Describe("LookupContainer", func() {
+		It("should fetch the container's properties", func() {
+			fakeGordon.SetInfoResponse(&warden.InfoResponse{
+				Properties: []*warden.Property{
+					{Key: proto.String("owner"), Value: proto.String("executor")},
+				},
+			})
+
+			container, err := LookupContainer(fakeGordon, "some-handle")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(container.Handle()).Should(Equal("some-handle"))
+
+			properties, err := container.Properties()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(properties).Should(Equal(map[string]string{"owner": "executor"}))
+		})
+
+		It("should fail if the container doesn't exist", func() {
+			fakeGordon.SetInfoError(errors.New("unknown handle"))
+
+			_, err := LookupContainer(fakeGordon, "some-handle")
+			Ω(err).Should(Equal(errors.New("unknown handle")))
+		})
+	})
+
+	Describe("ListContainers", func() {
+		It("should return a container for each handle", func() {
+			fakeGordon.WhenListing(func(filter map[string]string) (*warden.ListResponse, error) {
+				Ω(filter).Should(Equal(map[string]string{"owner": "executor"}))
+				return &warden.ListResponse{Handles: []string{"a", "b"}}, nil
+			})
+
+			containers, err := ListContainers(fakeGordon, map[string]string{"owner": "executor"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(containers).Should(HaveLen(2))
+			Ω(containers[0].Handle()).Should(Equal("a"))
+			Ω(containers[1].Handle()).Should(Equal("b"))
+		})
+	})
+
+	Describe("operating on the container", func() {
+		var container *Container
+
+		BeforeEach(func() {
+			container = NewContainer(fakeGordon, "some-handle")
+		})
+
+		It("should pass the handle along", func() {
+			_, err := container.Stop(false, true)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeGordon.StoppedHandles()).Should(Equal([]string{"some-handle"}))
+
+			_, err = container.Destroy()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeGordon.DestroyedHandles()).Should(Equal([]string{"some-handle"}))
+
+			_, err = container.LimitMemory(1024)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeGordon.MemoryLimits()).Should(Equal([]fake_gordon.Limit{{Handle: "some-handle", Limit: 1024}}))
+
+			_, err = container.LimitCPU(10)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeGordon.CPULimits()).Should(Equal([]fake_gordon.Limit{{Handle: "some-handle", Limit: 10}}))
+
+			_, err = container.LimitDisk(DiskLimits{ByteLimit: 2048})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeGordon.DiskLimits()).Should(Equal([]fake_gordon.DiskLimit{
+				{Handle: "some-handle", Limits: DiskLimits{ByteLimit: 2048}},
+			}))
+
+			_, err = container.CopyIn("/src", "/dst")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeGordon.ThingsCopiedIn()).Should(Equal([]*fake_gordon.CopiedIn{
+				{Handle: "some-handle", Src: "/src", Dst: "/dst"},
+			}))
+
+			_, err = container.CopyOut("/src", "/dst", "vcap")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeGordon.ThingsCopiedOut()).Should(Equal([]*fake_gordon.CopiedOut{
+				{Handle: "some-handle", Src: "/src", Dst: "/dst", Owner: "vcap"},
+			}))
+
+			_, _, err = container.Run("echo hi", ResourceLimits{FileDescriptors: 10}, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeGordon.ScriptsThatRan()).Should(Equal([]*fake_gordon.RunningScript{
+				{Handle: "some-handle", Script: "echo hi", ResourceLimits: ResourceLimits{FileDescriptors: 10}},
+			}))
+		})
+
+		It("should fetch info for the handle", func() {
+			fakeGordon.SetInfoResponse(&warden.InfoResponse{State: proto.String("active")})
+
+			info, err := container.Info()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(info.GetState()).Should(Equal("active"))
+		})
+	})
+})
@@ -0,0 +1,144 @@
+package gordon_test
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	. "github.com/cloudfoundry-incubator/gordon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("Building exec scripts", func() {
+	// runs the script with a local shell, exec'ing a printf that writes each
+	// argument followed by a NUL, and returns the arguments it saw
+	roundTrip := func(args ...string) []string {
+		script, err := ExecScript(append([]string{"printf", `%s\0`}, args...))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		output, err := exec.Command("/bin/sh", "-c", script).Output()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		return strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+	}
+
+	It("quotes each argument", func() {
+		script, err := ExecScript([]string{"echo", "hello world", "it's"})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(script).Should(Equal(`exec 'echo' 'hello world' 'it'"'"'s'`))
+	})
+
+	It("refuses an empty argv", func() {
+		_, err := ExecScript(nil)
+		Ω(err).Should(Equal(ErrEmptyArgv))
+	})
+
+	It("refuses arguments containing a NUL, which can't be passed through", func() {
+		_, err := ExecScript([]string{"echo", "before\x00after"})
+		Ω(err).Should(Equal(ErrNULInArgv))
+	})
+
+	trickyArgs := map[string]string{
+		"single quotes":       `it's a 'quote'`,
+		"double quotes":       `say "hi"`,
+		"dollars":             `$HOME ${PATH} $(whoami) $1 $$`,
+		"backticks":           "`whoami`",
+		"backslashes":         `C:\path\n\\`,
+		"newlines":            "one\ntwo\n",
+		"tabs and spaces":     "  a\tb  ",
+		"globs":               "* ? [a-z]",
+		"unicode":             "héllo, 世界 🚀",
+		"shell operators":     "a; b && c || d | e > f < g & h",
+		"comments":            "# not a comment",
+		"leading dashes":      "-n",
+		"an empty string":     "",
+		"history expansions":  "!! !$",
+		"tildes":              "~ ~root",
+		"assignments":         "FOO=bar",
+		"consecutive quotes":  `''''`,
+		"mixed quote nesting": `'"'"'`,
+	}
+
+	for description, arg := range trickyArgs {
+		description, arg := description, arg
+
+		It("passes "+description+" through untouched", func() {
+			Ω(roundTrip(arg, "after")).Should(Equal([]string{arg, "after"}))
+		})
+	}
+
+	It("passes many tricky arguments at once", func() {
+		args := []string{}
+		for _, arg := range trickyArgs {
+			args = append(args, arg)
+		}
+
+		Ω(roundTrip(args...)).Should(Equal(args))
+	})
+})
+
+var _ = Describe("Exec", func() {
+	var (
+		client      Client
+		writeBuffer *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		writeBuffer = new(bytes.Buffer)
+
+		client = NewClient(NewFakeConnectionProvider(
+			warden.Messages(
+				&warden.ProcessPayload{ProcessId: proto.Uint32(1721)},
+				&warden.ProcessPayload{ProcessId: proto.Uint32(1721), ExitStatus: proto.Uint32(0)},
+			),
+			writeBuffer,
+		))
+
+		Ω(client.Connect()).Should(Succeed())
+	})
+
+	It("runs the quoted argv with the environment and limits", func() {
+		processID, payloads, err := client.Exec(
+			"foo",
+			[]string{"echo", "$HOME", "it's\nfine"},
+			[]EnvironmentVariable{{Key: "HOME", Value: "/app"}},
+			ResourceLimits{FileDescriptors: 72},
+		)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(processID).Should(BeNumerically("==", 1721))
+
+		Eventually(payloads).Should(BeClosed())
+
+		expectedWriteBufferContents := string(warden.Messages(
+			&warden.RunRequest{
+				Handle:  proto.String("foo"),
+				Script:  proto.String("exec 'echo' '$HOME' 'it'\"'\"'s\nfine'"),
+				Rlimits: &warden.ResourceLimits{Nofile: proto.Uint64(72)},
+				Env: []*warden.EnvironmentVariable{
+					{Key: proto.String("HOME"), Value: proto.String("/app")},
+				},
+			},
+		).Bytes())
+
+		Ω(writeBuffer.String()).Should(Equal(expectedWriteBufferContents))
+	})
+
+	It("sends nothing for an empty argv", func() {
+		_, _, err := client.Exec("foo", []string{}, nil, ResourceLimits{})
+		Ω(err).Should(Equal(ErrEmptyArgv))
+
+		Ω(writeBuffer.Len()).Should(BeZero())
+	})
+
+	It("sends nothing for an argument containing a NUL", func() {
+		_, _, err := client.Exec("foo", []string{"echo", "\x00"}, nil, ResourceLimits{})
+		Ω(err).Should(Equal(ErrNULInArgv))
+
+		Ω(writeBuffer.Len()).Should(BeZero())
+	})
+})
@@ -1,9 +1,13 @@
 package fake_gordon
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"io/ioutil"
 	"os"
 	"sync"
+	"time"
 
 	"code.google.com/p/gogoprotobuf/proto"
 	warden "github.com/cloudfoundry-incubator/garden/protocol"
@@ -15,6 +19,9 @@ type FakeGordon struct {
 	Connected    bool
 	ConnectError error
 
+	Closed     bool
+	CloseError error
+
 	createdHandles    []string
 	createdProperties map[string]map[string]string
 	CreateError       error
@@ -29,7 +36,9 @@ type FakeGordon struct {
 
 	LinkError error
 
-	NetInError error
+	netInHandles []string
+	netIns       []NetIn
+	NetInError   error
 
 	memoryLimits     []Limit
 	limitMemoryError error
@@ -44,14 +53,20 @@ type FakeGordon struct {
 
 	GetDiskLimitError error
 
+	GetCPULimitError error
+
 	listCallback ListCallback
 
 	infoError    error
 	infoResponse *warden.InfoResponse
+	infoCallback InfoCallback
 
-	AttachError error
+	AttachError    error
+	attachedTo     []*Attached
+	attachPayloads map[Attached]<-chan *warden.ProcessPayload
 
 	scriptsThatRan              []*RunningScript
+	specsThatRan                []*RanSpec
 	runCallbacks                map[*RunningScript]RunCallback
 	runReturnProcessID          uint32
 	runReturnProcessPayloadChan <-chan *warden.ProcessPayload
@@ -66,16 +81,62 @@ type FakeGordon struct {
 	fileContentToProvideOnCopyOut []byte
 	copyOutError                  error
 
+	streamedIn       []*StreamedIn
+	streamInError    error
+	streamOutContent []byte
+	streamOutError   error
+	streamedOutSrcs  []string
+
+	signalled   []*Signalled
+	signalError error
+
 	lock *sync.RWMutex
 }
 
 type ListCallback func(filterProperties map[string]string) (*warden.ListResponse, error)
 
+type InfoCallback func(handle string) (*warden.InfoResponse, error)
+
 type RunCallback func() (uint32, <-chan *warden.ProcessPayload, error)
 
+// RespondWith returns a RunCallback for a process that sends payloads and
+// then closes its stream.
+func RespondWith(payloads ...*warden.ProcessPayload) RunCallback {
+	return func() (uint32, <-chan *warden.ProcessPayload, error) {
+		stream := make(chan *warden.ProcessPayload, len(payloads))
+		for _, payload := range payloads {
+			stream <- payload
+		}
+
+		close(stream)
+
+		return 1, stream, nil
+	}
+}
+
+// ExitWith returns a RunCallback for a process that writes each output to
+// stdout and then exits with status.
+func ExitWith(status uint32, output ...string) RunCallback {
+	stdout := warden.ProcessPayload_stdout
+
+	payloads := []*warden.ProcessPayload{}
+	for _, data := range output {
+		payloads = append(payloads, &warden.ProcessPayload{Source: &stdout, Data: proto.String(data)})
+	}
+
+	payloads = append(payloads, &warden.ProcessPayload{ExitStatus: proto.Uint32(status)})
+
+	return RespondWith(payloads...)
+}
+
 type CopyInCallback func(CopiedIn) error
 type CopyOutCallback func(CopiedOut) error
 
+type Attached struct {
+	Handle    string
+	ProcessID uint32
+}
+
 type RunningScript struct {
 	Handle               string
 	Script               string
@@ -96,6 +157,29 @@ type CopiedOut struct {
 	Owner  string
 }
 
+type RanSpec struct {
+	Handle string
+	Spec   gordon.RunSpec
+}
+
+type Signalled struct {
+	Handle    string
+	ProcessID uint32
+	Signal    string
+}
+
+type StreamedIn struct {
+	Handle string
+	Dst    string
+	Data   []byte
+}
+
+type NetIn struct {
+	Handle        string
+	HostPort      uint32
+	ContainerPort uint32
+}
+
 type Limit struct {
 	Handle string
 	Limit  uint64
@@ -117,6 +201,9 @@ func (f *FakeGordon) Reset() {
 	f.Connected = false
 	f.ConnectError = nil
 
+	f.Closed = false
+	f.CloseError = nil
+
 	f.createdHandles = []string{}
 	f.createdProperties = map[string]map[string]string{}
 	f.CreateError = nil
@@ -130,11 +217,17 @@ func (f *FakeGordon) Reset() {
 	f.SpawnError = nil
 	f.LinkError = nil
 	f.NetInError = nil
+	f.netInHandles = []string{}
+	f.netIns = []NetIn{}
 	f.GetMemoryLimitError = nil
 	f.GetDiskLimitError = nil
+	f.GetCPULimitError = nil
 	f.AttachError = nil
+	f.attachedTo = []*Attached{}
+	f.attachPayloads = map[Attached]<-chan *warden.ProcessPayload{}
 
 	f.infoError = nil
+	f.infoCallback = nil
 
 	f.limitMemoryError = nil
 	f.limitDiskError = nil
@@ -142,6 +235,7 @@ func (f *FakeGordon) Reset() {
 	f.diskLimits = []DiskLimit{}
 
 	f.scriptsThatRan = make([]*RunningScript, 0)
+	f.specsThatRan = make([]*RanSpec, 0)
 	f.runCallbacks = make(map[*RunningScript]RunCallback)
 	f.runReturnProcessID = 0
 	f.runReturnError = nil
@@ -153,6 +247,15 @@ func (f *FakeGordon) Reset() {
 	f.copyInCallbacks = make(map[*CopiedIn]CopyInCallback)
 	f.copyOutCallbacks = make(map[*CopiedOut]CopyOutCallback)
 	f.fileContentToProvideOnCopyOut = []byte{}
+
+	f.streamedIn = []*StreamedIn{}
+	f.streamInError = nil
+	f.streamOutContent = []byte{}
+	f.streamOutError = nil
+	f.streamedOutSrcs = []string{}
+
+	f.signalled = []*Signalled{}
+	f.signalError = nil
 }
 
 func (f *FakeGordon) Connect() error {
@@ -162,6 +265,17 @@ func (f *FakeGordon) Connect() error {
 	return f.ConnectError
 }
 
+func (f *FakeGordon) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.Closed = true
+	return f.CloseError
+}
+
+func (f *FakeGordon) Shutdown(ctx context.Context) error {
+	return f.Close()
+}
+
 func (f *FakeGordon) Create(properties map[string]string) (*warden.CreateResponse, error) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -234,8 +348,52 @@ func (f *FakeGordon) DestroyedHandles() []string {
 }
 
 func (f *FakeGordon) NetIn(handle string) (*warden.NetInResponse, error) {
-	panic("NOOP!")
-	return nil, f.NetInError
+	return f.NetInWithPorts(handle, 0, 0)
+}
+
+func (f *FakeGordon) NetInWithPorts(handle string, hostPort, containerPort uint32) (*warden.NetInResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.NetInError != nil {
+		return nil, f.NetInError
+	}
+
+	f.netInHandles = append(f.netInHandles, handle)
+	f.netIns = append(f.netIns, NetIn{
+		Handle:        handle,
+		HostPort:      hostPort,
+		ContainerPort: containerPort,
+	})
+
+	// like warden, pick a host port if none was given, and map the same port
+	// on both sides unless told otherwise
+	if hostPort == 0 {
+		hostPort = uint32(61000 + len(f.netInHandles) - 1)
+	}
+
+	if containerPort == 0 {
+		containerPort = hostPort
+	}
+
+	return &warden.NetInResponse{
+		HostPort:      proto.Uint32(hostPort),
+		ContainerPort: proto.Uint32(containerPort),
+	}, nil
+}
+
+func (f *FakeGordon) NetInHandles() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.netInHandles
+}
+
+// NetIns returns the ports each call asked for, zero meaning unset.
+func (f *FakeGordon) NetIns() []NetIn {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.netIns
 }
 
 func (f *FakeGordon) MemoryLimits() []Limit {
@@ -264,9 +422,26 @@ func (f *FakeGordon) LimitMemory(handle string, limit uint64) (*warden.LimitMemo
 	return nil, f.limitMemoryError
 }
 
+// GetMemoryLimit returns the last limit set for the handle, or 0 if there
+// isn't one, like warden for an unlimited container.
 func (f *FakeGordon) GetMemoryLimit(handle string) (uint64, error) {
-	panic("NOOP!")
-	return 0, f.GetMemoryLimitError
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.GetMemoryLimitError != nil {
+		return 0, f.GetMemoryLimitError
+	}
+
+	return lastLimit(f.memoryLimits, handle), nil
+}
+
+func (f *FakeGordon) GetMemoryLimits(handle string) (gordon.MemoryLimits, error) {
+	limit, err := f.GetMemoryLimit(handle)
+	if err != nil {
+		return gordon.MemoryLimits{}, err
+	}
+
+	return gordon.MemoryLimits{LimitInBytes: limit}, nil
 }
 
 func (f *FakeGordon) DiskLimits() []DiskLimit {
@@ -321,9 +496,60 @@ func (f *FakeGordon) CPULimits() []Limit {
 	return f.cpuLimits
 }
 
+func (f *FakeGordon) GetCPULimit(handle string) (uint64, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.GetCPULimitError != nil {
+		return 0, f.GetCPULimitError
+	}
+
+	return lastLimit(f.cpuLimits, handle), nil
+}
+
+func (f *FakeGordon) GetCPULimits(handle string) (gordon.CPULimits, error) {
+	limit, err := f.GetCPULimit(handle)
+	if err != nil {
+		return gordon.CPULimits{}, err
+	}
+
+	return gordon.CPULimits{LimitInShares: limit}, nil
+}
+
 func (f *FakeGordon) GetDiskLimit(handle string) (uint64, error) {
-	panic("NOOP!")
-	return 0, f.GetDiskLimitError
+	limits, err := f.GetDiskLimits(handle)
+	if err != nil {
+		return 0, err
+	}
+
+	return limits.ByteLimit, nil
+}
+
+func (f *FakeGordon) GetDiskLimits(handle string) (gordon.DiskLimits, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.GetDiskLimitError != nil {
+		return gordon.DiskLimits{}, f.GetDiskLimitError
+	}
+
+	for i := len(f.diskLimits) - 1; i >= 0; i-- {
+		if f.diskLimits[i].Handle == handle {
+			return f.diskLimits[i].Limits, nil
+		}
+	}
+
+	return gordon.DiskLimits{}, nil
+}
+
+func lastLimit(limits []Limit, handle string) uint64 {
+	for i := len(limits) - 1; i >= 0; i-- {
+		if limits[i].Handle == handle {
+			return limits[i].Limit
+		}
+	}
+
+	return 0
 }
 
 func (f *FakeGordon) List(filterProperties map[string]string) (*warden.ListResponse, error) {
@@ -339,6 +565,14 @@ func (f *FakeGordon) List(filterProperties map[string]string) (*warden.ListRespo
 }
 
 func (f *FakeGordon) Info(handle string) (*warden.InfoResponse, error) {
+	f.lock.RLock()
+	callback := f.infoCallback
+	f.lock.RUnlock()
+
+	if callback != nil {
+		return callback(handle)
+	}
+
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
@@ -357,6 +591,41 @@ func (f *FakeGordon) SetInfoResponse(response *warden.InfoResponse) {
 	f.infoResponse = response
 }
 
+func (f *FakeGordon) GetHandles(filterProperties map[string]string) ([]string, error) {
+	res, err := f.List(filterProperties)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.GetHandles(), nil
+}
+
+func (f *FakeGordon) GetInfo(handle string) (gordon.ContainerInfo, error) {
+	res, err := f.Info(handle)
+	if err != nil {
+		return gordon.ContainerInfo{}, err
+	}
+
+	return gordon.NewContainerInfo(res), nil
+}
+
+func (f *FakeGordon) BulkInfo(handles []string, concurrency int) map[string]gordon.BulkInfoEntry {
+	results := map[string]gordon.BulkInfoEntry{}
+
+	for _, handle := range handles {
+		info, err := f.Info(handle)
+		results[handle] = gordon.BulkInfoEntry{Info: info, Err: err}
+	}
+
+	return results
+}
+
+// Watch polls the fake's List and Info, so set up WhenListing and
+// WhenGettingInfo to drive it.
+func (f *FakeGordon) Watch(filterProperties map[string]string, interval time.Duration) *gordon.Watcher {
+	return gordon.NewWatcher(f, filterProperties, interval)
+}
+
 func (f *FakeGordon) CopyIn(handle, src, dst string) (*warden.CopyInResponse, error) {
 	f.lock.RLock()
 	err := f.copyInError
@@ -466,9 +735,42 @@ func (f *FakeGordon) SetCopyOutFileContent(data []byte) {
 	f.fileContentToProvideOnCopyOut = data
 }
 
-func (f *FakeGordon) Attach(handle string, jobID uint32) (<-chan *warden.ProcessPayload, error) {
-	panic("NOOP!")
-	return nil, f.AttachError
+// Attach returns the payloads set with SetAttachPayloads, or a closed
+// channel if there are none.
+func (f *FakeGordon) Attach(handle string, processID uint32) (<-chan *warden.ProcessPayload, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.AttachError != nil {
+		return nil, f.AttachError
+	}
+
+	attached := Attached{Handle: handle, ProcessID: processID}
+	f.attachedTo = append(f.attachedTo, &attached)
+
+	payloads, found := f.attachPayloads[attached]
+	if !found {
+		closed := make(chan *warden.ProcessPayload)
+		close(closed)
+
+		return closed, nil
+	}
+
+	return payloads, nil
+}
+
+func (f *FakeGordon) SetAttachPayloads(handle string, processID uint32, payloads <-chan *warden.ProcessPayload) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.attachPayloads[Attached{Handle: handle, ProcessID: processID}] = payloads
+}
+
+func (f *FakeGordon) ThingsAttachedTo() []*Attached {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.attachedTo
 }
 
 func (f *FakeGordon) ScriptsThatRan() []*RunningScript {
@@ -477,6 +779,113 @@ func (f *FakeGordon) ScriptsThatRan() []*RunningScript {
 	return f.scriptsThatRan
 }
 
+func (f *FakeGordon) StreamIn(handle, dst string, r io.Reader) error {
+	f.lock.RLock()
+	err := f.streamInError
+	f.lock.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.streamedIn = append(f.streamedIn, &StreamedIn{
+		Handle: handle,
+		Dst:    dst,
+		Data:   data,
+	})
+
+	return nil
+}
+
+func (f *FakeGordon) ThingsStreamedIn() []*StreamedIn {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.streamedIn
+}
+
+func (f *FakeGordon) SetStreamInErr(err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.streamInError = err
+}
+
+// Signal records the signal; any process can be signalled, signalable or
+// not.
+func (f *FakeGordon) Signal(handle string, processID uint32, signal string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.signalError != nil {
+		return f.signalError
+	}
+
+	f.signalled = append(f.signalled, &Signalled{
+		Handle:    handle,
+		ProcessID: processID,
+		Signal:    signal,
+	})
+
+	return nil
+}
+
+func (f *FakeGordon) ThingsSignalled() []*Signalled {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.signalled
+}
+
+func (f *FakeGordon) SetSignalErr(err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.signalError = err
+}
+
+func (f *FakeGordon) StreamOut(handle, src string) (io.ReadCloser, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.streamOutError != nil {
+		return nil, f.streamOutError
+	}
+
+	f.streamedOutSrcs = append(f.streamedOutSrcs, src)
+
+	return ioutil.NopCloser(bytes.NewReader(f.streamOutContent)), nil
+}
+
+func (f *FakeGordon) ThingsStreamedOut() []string {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.streamedOutSrcs
+}
+
+func (f *FakeGordon) SetStreamOutErr(err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.streamOutError = err
+}
+
+func (f *FakeGordon) SetStreamOutContent(data []byte) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.streamOutContent = data
+}
+
 func (f *FakeGordon) SetRunReturnValues(processID uint32, processPayloadChan <-chan *warden.ProcessPayload, err error) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -499,6 +908,13 @@ func (f *FakeGordon) WhenListing(callback ListCallback) {
 	f.listCallback = callback
 }
 
+func (f *FakeGordon) WhenGettingInfo(callback InfoCallback) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.infoCallback = callback
+}
+
 func (f *FakeGordon) WhenCopyingOut(copiedOut CopiedOut, callback CopyOutCallback) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -533,3 +949,54 @@ func (f *FakeGordon) Run(handle string, script string, resourceLimits gordon.Res
 
 	return f.runReturnProcessID, f.runReturnProcessPayloadChan, f.runReturnError
 }
+
+// Exec runs the quoted script, so WhenRunning callbacks must be registered
+// for gordon.ExecScript(argv).
+func (f *FakeGordon) Exec(handle string, argv []string, environmentVariables []gordon.EnvironmentVariable, resourceLimits gordon.ResourceLimits) (uint32, <-chan *warden.ProcessPayload, error) {
+	script, err := gordon.ExecScript(argv)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return f.Run(handle, script, resourceLimits, environmentVariables)
+}
+
+func (f *FakeGordon) RunStream(handle string, script string, resourceLimits gordon.ResourceLimits, environmentVariables []gordon.EnvironmentVariable) (*gordon.ProcessStream, error) {
+	processID, payloads, err := f.Run(handle, script, resourceLimits, environmentVariables)
+	if err != nil {
+		return nil, err
+	}
+
+	return gordon.NewProcessStream(processID, payloads, nil), nil
+}
+
+// RunWithSpec records the spec and then behaves like RunStream for its
+// unwrapped script, so WhenRunning callbacks apply.
+func (f *FakeGordon) RunWithSpec(handle string, spec gordon.RunSpec) (*gordon.ProcessStream, error) {
+	_, err := spec.WrappedScript()
+	if err != nil {
+		return nil, err
+	}
+
+	f.lock.Lock()
+	f.specsThatRan = append(f.specsThatRan, &RanSpec{Handle: handle, Spec: spec})
+	f.lock.Unlock()
+
+	return f.RunStream(handle, spec.Script, spec.ResourceLimits, spec.EnvironmentVariables)
+}
+
+func (f *FakeGordon) SpecsThatRan() []*RanSpec {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.specsThatRan
+}
+
+func (f *FakeGordon) AttachStream(handle string, processID uint32) (*gordon.ProcessStream, error) {
+	payloads, err := f.Attach(handle, processID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gordon.NewProcessStream(processID, payloads, nil), nil
+}
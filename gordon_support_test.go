@@ -3,6 +3,11 @@ package gordon_test
 import (
 	"bytes"
 	"errors"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+
 	. "github.com/cloudfoundry-incubator/gordon"
 	. "github.com/cloudfoundry-incubator/gordon/test_helpers"
 
@@ -30,15 +35,33 @@ func NewFakeConnectionProvider(readBuffer, writeBuffer *bytes.Buffer) *FakeConne
 	}
 }
 
+// NewPipeConnectionProvider provides a connection that sends replies and then
+// stays open, copying what the client writes to writeBuffer.
+func NewPipeConnectionProvider(replies, writeBuffer *bytes.Buffer) *FakeConnectionProvider {
+	return &FakeConnectionProvider{
+		connection: connection.New(
+			&recordingConn{
+				Conn:    NewPipeConn(replies),
+				written: writeBuffer,
+			},
+		),
+	}
+}
+
 func (c *FakeConnectionProvider) ProvideConnection() (*connection.Connection, error) {
 	return c.connection, nil
 }
 
 type ManyConnectionProvider struct {
 	ConnectionProviders []ConnectionProvider
+
+	lock sync.Mutex
 }
 
 func (c *ManyConnectionProvider) ProvideConnection() (*connection.Connection, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
 	if len(c.ConnectionProviders) == 0 {
 		return nil, errors.New("no more connections")
 	}
@@ -48,3 +71,66 @@ func (c *ManyConnectionProvider) ProvideConnection() (*connection.Connection, er
 
 	return cp.ProvideConnection()
 }
+
+// signallingReader closes reading when it is first read from.
+type signallingReader struct {
+	io.Reader
+
+	reading chan struct{}
+	once    sync.Once
+}
+
+func (r *signallingReader) Read(p []byte) (int, error) {
+	r.once.Do(func() { close(r.reading) })
+	return r.Reader.Read(p)
+}
+
+// recordingConn copies writes to written before sending them, in the
+// writer's goroutine, so written can be read once the write has returned.
+type recordingConn struct {
+	net.Conn
+
+	written *bytes.Buffer
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	c.written.Write(p)
+	return c.Conn.Write(p)
+}
+
+// keepAliveEnabled finds this process's socket bound to addr, i.e. the
+// client's side of a connection, and reports whether it has SO_KEEPALIVE set
+func keepAliveEnabled(addr net.Addr) bool {
+	port := addr.(*net.TCPAddr).Port
+
+	for fd := 0; fd < 1024; fd++ {
+		sockaddr, err := syscall.Getsockname(fd)
+		if err != nil {
+			continue
+		}
+
+		var localPort int
+
+		switch sockaddr := sockaddr.(type) {
+		case *syscall.SockaddrInet4:
+			localPort = sockaddr.Port
+		case *syscall.SockaddrInet6:
+			localPort = sockaddr.Port
+		default:
+			continue
+		}
+
+		if localPort != port {
+			continue
+		}
+
+		enabled, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+		if err != nil {
+			panic(err)
+		}
+
+		return enabled != 0
+	}
+
+	panic("no socket bound to " + addr.String())
+}
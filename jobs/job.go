@@ -0,0 +1,138 @@
+// Package jobs runs a graph of dependent scripts across containers.
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon"
+)
+
+var ErrNoHandle = errors.New("step has no handle and the job has no default")
+
+type Job struct {
+	// Handle is the container steps run in unless they name their own.
+	Handle string
+
+	Steps []Step
+}
+
+type Step struct {
+	Name   string
+	Handle string
+
+	Script               string
+	EnvironmentVariables []gordon.EnvironmentVariable
+	ResourceLimits       gordon.ResourceLimits
+
+	// Timeout, if set, bounds how long the step may run before its process
+	// is killed.
+	Timeout time.Duration
+
+	// DependsOn names the steps that must succeed before this one starts.
+	DependsOn []string
+}
+
+type DuplicateStepError struct {
+	Step string
+}
+
+func (e DuplicateStepError) Error() string {
+	return fmt.Sprintf("duplicate step: %s", e.Step)
+}
+
+type UnknownDependencyError struct {
+	Step       string
+	Dependency string
+}
+
+func (e UnknownDependencyError) Error() string {
+	return fmt.Sprintf("step %s depends on unknown step %s", e.Step, e.Dependency)
+}
+
+type CycleError struct {
+	Steps []string
+}
+
+func (e CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Steps, " -> "))
+}
+
+// Validate checks that step names are unique, every dependency exists and
+// there are no cycles.
+func (j Job) Validate() error {
+	steps := map[string]Step{}
+
+	for _, step := range j.Steps {
+		if _, found := steps[step.Name]; found {
+			return DuplicateStepError{step.Name}
+		}
+
+		if step.Handle == "" && j.Handle == "" {
+			return ErrNoHandle
+		}
+
+		steps[step.Name] = step
+	}
+
+	for _, step := range j.Steps {
+		for _, dependency := range step.DependsOn {
+			if _, found := steps[dependency]; !found {
+				return UnknownDependencyError{step.Name, dependency}
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := map[string]int{}
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for path[start] != name {
+				start++
+			}
+
+			return CycleError{path[start:]}
+		}
+
+		state[name] = visiting
+
+		dependencies := append([]string{}, steps[name].DependsOn...)
+		sort.Strings(dependencies)
+
+		for _, dependency := range dependencies {
+			err := visit(dependency, path)
+			if err != nil {
+				return err
+			}
+		}
+
+		state[name] = visited
+
+		return nil
+	}
+
+	for _, step := range j.Steps {
+		err := visit(step.Name, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,374 @@
+package jobs_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/cloudfoundry-incubator/gordon/jobs"
+	"github.com/cloudfoundry-incubator/gordon/logs"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("Validating jobs", func() {
+	It("accepts a valid graph", func() {
+		job := Job{
+			Handle: "some-handle",
+			Steps: []Step{
+				{Name: "fetch"},
+				{Name: "build", DependsOn: []string{"fetch"}},
+				{Name: "test", DependsOn: []string{"build"}},
+				{Name: "lint", DependsOn: []string{"fetch"}},
+				{Name: "package", DependsOn: []string{"test", "lint"}},
+			},
+		}
+
+		Ω(job.Validate()).Should(Succeed())
+	})
+
+	It("rejects duplicate step names", func() {
+		job := Job{Handle: "some-handle", Steps: []Step{{Name: "a"}, {Name: "a"}}}
+		Ω(job.Validate()).Should(Equal(DuplicateStepError{"a"}))
+	})
+
+	It("rejects unknown dependencies", func() {
+		job := Job{Handle: "some-handle", Steps: []Step{{Name: "a", DependsOn: []string{"b"}}}}
+		Ω(job.Validate()).Should(Equal(UnknownDependencyError{"a", "b"}))
+	})
+
+	It("rejects cycles", func() {
+		job := Job{
+			Handle: "some-handle",
+			Steps: []Step{
+				{Name: "a", DependsOn: []string{"c"}},
+				{Name: "b", DependsOn: []string{"a"}},
+				{Name: "c", DependsOn: []string{"b"}},
+			},
+		}
+
+		Ω(job.Validate()).Should(Equal(CycleError{[]string{"a", "c", "b", "a"}}))
+	})
+
+	It("rejects steps with nowhere to run", func() {
+		job := Job{Steps: []Step{{Name: "a"}}}
+		Ω(job.Validate()).Should(Equal(ErrNoHandle))
+	})
+})
+
+var _ = Describe("Running jobs", func() {
+	var (
+		fakeGordon *fake_gordon.FakeGordon
+		runner     *Runner
+		config     Config
+
+		lock  sync.Mutex
+		order []string
+	)
+
+	exitWith := func(name string, status uint32, output string) fake_gordon.RunCallback {
+		exit := fake_gordon.ExitWith(status, output)
+
+		return func() (uint32, <-chan *warden.ProcessPayload, error) {
+			lock.Lock()
+			order = append(order, name)
+			lock.Unlock()
+
+			return exit()
+		}
+	}
+
+	BeforeEach(func() {
+		fakeGordon = fake_gordon.New()
+		config = Config{}
+		order = nil
+	})
+
+	JustBeforeEach(func() {
+		runner = New(fakeGordon, config)
+	})
+
+	It("runs steps after their dependencies", func() {
+		fakeGordon.WhenRunning("some-handle", "fetch", gordon.ResourceLimits{}, nil, exitWith("fetch", 0, ""))
+		fakeGordon.WhenRunning("some-handle", "build", gordon.ResourceLimits{}, nil, exitWith("build", 0, ""))
+		fakeGordon.WhenRunning("some-handle", "test", gordon.ResourceLimits{}, nil, exitWith("test", 0, ""))
+
+		result, err := runner.Run(Job{
+			Handle: "some-handle",
+			Steps: []Step{
+				{Name: "test", Script: "test", DependsOn: []string{"build"}},
+				{Name: "build", Script: "build", DependsOn: []string{"fetch"}},
+				{Name: "fetch", Script: "fetch"},
+			},
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(result.Succeeded()).Should(BeTrue())
+		Ω(order).Should(Equal([]string{"fetch", "build", "test"}))
+	})
+
+	It("runs each step with its own spec and container", func() {
+		fakeGordon.WhenRunning("other-handle", "build", gordon.ResourceLimits{}, nil, exitWith("build", 0, ""))
+
+		result, err := runner.Run(Job{
+			Handle: "some-handle",
+			Steps: []Step{
+				{
+					Name:                 "build",
+					Handle:               "other-handle",
+					Script:               "build",
+					EnvironmentVariables: []gordon.EnvironmentVariable{{Key: "GOPATH", Value: "/go"}},
+					ResourceLimits:       gordon.ResourceLimits{Nproc: 64},
+				},
+			},
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Steps["build"].Handle).Should(Equal("other-handle"))
+
+		specs := fakeGordon.SpecsThatRan()
+		Ω(specs).Should(HaveLen(1))
+		Ω(specs[0].Handle).Should(Equal("other-handle"))
+		Ω(specs[0].Spec).Should(Equal(gordon.RunSpec{
+			Script:               "build",
+			EnvironmentVariables: []gordon.EnvironmentVariable{{Key: "GOPATH", Value: "/go"}},
+			ResourceLimits:       gordon.ResourceLimits{Nproc: 64},
+			Signalable:           true,
+		}))
+	})
+
+	It("runs independent steps concurrently", func() {
+		started := make(chan struct{}, 2)
+		release := make(chan struct{})
+
+		blocking := func() (uint32, <-chan *warden.ProcessPayload, error) {
+			started <- struct{}{}
+
+			payloads := make(chan *warden.ProcessPayload)
+			go func() {
+				<-release
+				payloads <- &warden.ProcessPayload{ExitStatus: proto.Uint32(0)}
+			}()
+
+			return 1, payloads, nil
+		}
+
+		fakeGordon.WhenRunning("some-handle", "a", gordon.ResourceLimits{}, nil, blocking)
+		fakeGordon.WhenRunning("some-handle", "b", gordon.ResourceLimits{}, nil, blocking)
+
+		results := make(chan *Result)
+		go func() {
+			defer GinkgoRecover()
+
+			result, err := runner.Run(Job{
+				Handle: "some-handle",
+				Steps:  []Step{{Name: "a", Script: "a"}, {Name: "b", Script: "b"}},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			results <- result
+		}()
+
+		Eventually(started).Should(Receive())
+		Eventually(started).Should(Receive())
+
+		close(release)
+
+		var result *Result
+		Eventually(results).Should(Receive(&result))
+		Ω(result.Succeeded()).Should(BeTrue())
+	})
+
+	Context("with a concurrency limit", func() {
+		BeforeEach(func() {
+			config.Concurrency = 1
+		})
+
+		It("runs one step at a time", func() {
+			running := 0
+			maxRunning := 0
+
+			oneAtATime := func() (uint32, <-chan *warden.ProcessPayload, error) {
+				lock.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				lock.Unlock()
+
+				payloads := make(chan *warden.ProcessPayload)
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+
+					lock.Lock()
+					running--
+					lock.Unlock()
+
+					payloads <- &warden.ProcessPayload{ExitStatus: proto.Uint32(0)}
+				}()
+
+				return 1, payloads, nil
+			}
+
+			fakeGordon.WhenRunning("some-handle", "a", gordon.ResourceLimits{}, nil, oneAtATime)
+			fakeGordon.WhenRunning("some-handle", "b", gordon.ResourceLimits{}, nil, oneAtATime)
+			fakeGordon.WhenRunning("some-handle", "c", gordon.ResourceLimits{}, nil, oneAtATime)
+
+			result, err := runner.Run(Job{
+				Handle: "some-handle",
+				Steps:  []Step{{Name: "a", Script: "a"}, {Name: "b", Script: "b"}, {Name: "c", Script: "c"}},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(result.Succeeded()).Should(BeTrue())
+
+			Ω(maxRunning).Should(Equal(1))
+		})
+	})
+
+	Context("when a step fails", func() {
+		var result *Result
+
+		JustBeforeEach(func() {
+			fakeGordon.WhenRunning("some-handle", "fetch", gordon.ResourceLimits{}, nil, exitWith("fetch", 0, ""))
+			fakeGordon.WhenRunning("some-handle", "build", gordon.ResourceLimits{}, nil, exitWith("build", 2, ""))
+			fakeGordon.WhenRunning("some-handle", "lint", gordon.ResourceLimits{}, nil, exitWith("lint", 0, ""))
+
+			var err error
+			result, err = runner.Run(Job{
+				Handle: "some-handle",
+				Steps: []Step{
+					{Name: "fetch", Script: "fetch"},
+					{Name: "build", Script: "build", DependsOn: []string{"fetch"}},
+					{Name: "test", Script: "test", DependsOn: []string{"build"}},
+					{Name: "package", Script: "package", DependsOn: []string{"test"}},
+					{Name: "lint", Script: "lint", DependsOn: []string{"fetch"}},
+				},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("reports its exit status", func() {
+			Ω(result.Succeeded()).Should(BeFalse())
+
+			Ω(result.Steps["build"].Status).Should(Equal(Failed))
+			Ω(result.Steps["build"].ExitStatus).Should(Equal(uint32(2)))
+		})
+
+		It("skips the steps that depend on it, directly or not", func() {
+			Ω(result.Steps["test"].Status).Should(Equal(Skipped))
+			Ω(result.Steps["package"].Status).Should(Equal(Skipped))
+		})
+
+		It("carries on with independent steps", func() {
+			Ω(result.Steps["fetch"].Status).Should(Equal(Succeeded))
+			Ω(result.Steps["lint"].Status).Should(Equal(Succeeded))
+		})
+	})
+
+	Context("when a step can't be run", func() {
+		disaster := errors.New("oh no!")
+
+		BeforeEach(func() {
+			fakeGordon.WhenRunning("some-handle", "a", gordon.ResourceLimits{}, nil, func() (uint32, <-chan *warden.ProcessPayload, error) {
+				return 0, nil, disaster
+			})
+		})
+
+		It("reports the error", func() {
+			result, err := runner.Run(Job{Handle: "some-handle", Steps: []Step{{Name: "a", Script: "a"}}})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(result.Steps["a"].Status).Should(Equal(Errored))
+			Ω(result.Steps["a"].Err).Should(Equal(disaster))
+		})
+	})
+
+	Context("when a step outlives its timeout", func() {
+		BeforeEach(func() {
+			fakeGordon.WhenRunning("some-handle", "sleep 100", gordon.ResourceLimits{}, nil, func() (uint32, <-chan *warden.ProcessPayload, error) {
+				return 42, make(chan *warden.ProcessPayload), nil
+			})
+		})
+
+		It("kills the step's process and reports it timed out", func() {
+			result, err := runner.Run(Job{
+				Handle: "some-handle",
+				Steps: []Step{
+					{Name: "slow", Script: "sleep 100", Timeout: 50 * time.Millisecond},
+					{Name: "after", Script: "after", DependsOn: []string{"slow"}},
+				},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(result.Steps["slow"].Status).Should(Equal(TimedOut))
+			Ω(result.Steps["slow"].Duration).Should(BeNumerically(">=", 50*time.Millisecond))
+			Ω(result.Steps["after"].Status).Should(Equal(Skipped))
+
+			Ω(fakeGordon.ThingsSignalled()).Should(Equal([]*fake_gordon.Signalled{
+				{Handle: "some-handle", ProcessID: 42, Signal: "KILL"},
+			}))
+		})
+	})
+
+	Context("with an output callback", func() {
+		var lines map[string][]string
+
+		BeforeEach(func() {
+			lines = map[string][]string{}
+
+			config.Output = func(step string, line logs.Line) {
+				lock.Lock()
+				defer lock.Unlock()
+
+				lines[step] = append(lines[step], line.Text)
+			}
+		})
+
+		It("streams each step's output line by line", func() {
+			fakeGordon.WhenRunning("some-handle", "a", gordon.ResourceLimits{}, nil, exitWith("a", 0, "one\ntwo\n"))
+			fakeGordon.WhenRunning("some-handle", "b", gordon.ResourceLimits{}, nil, exitWith("b", 0, "three"))
+
+			_, err := runner.Run(Job{
+				Handle: "some-handle",
+				Steps:  []Step{{Name: "a", Script: "a"}, {Name: "b", Script: "b"}},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(lines).Should(Equal(map[string][]string{
+				"a": {"one", "two"},
+				"b": {"three"},
+			}))
+		})
+	})
+
+	It("times every step and the whole job", func() {
+		now := time.Unix(1400000000, 0)
+
+		config.Now = func() time.Time {
+			lock.Lock()
+			defer lock.Unlock()
+
+			now = now.Add(time.Second)
+			return now
+		}
+
+		runner = New(fakeGordon, config)
+
+		fakeGordon.WhenRunning("some-handle", "a", gordon.ResourceLimits{}, nil, exitWith("a", 0, ""))
+
+		result, err := runner.Run(Job{Handle: "some-handle", Steps: []Step{{Name: "a", Script: "a"}}})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(result.Steps["a"].Duration).Should(Equal(time.Second))
+		Ω(result.Duration).Should(Equal(3 * time.Second))
+	})
+
+	It("refuses to run an invalid job", func() {
+		_, err := runner.Run(Job{Steps: []Step{{Name: "a"}}})
+		Ω(err).Should(Equal(ErrNoHandle))
+	})
+})
@@ -0,0 +1,215 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/logs"
+)
+
+type Status string
+
+const (
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	TimedOut  Status = "timed-out"
+	Errored   Status = "errored"
+	Skipped   Status = "skipped"
+)
+
+type StepResult struct {
+	Name   string
+	Handle string
+	Status Status
+
+	ProcessID  uint32
+	ExitStatus uint32
+
+	Started  time.Time
+	Duration time.Duration
+
+	// Err is set for Errored steps, e.g. when the run request failed.
+	Err error
+}
+
+type Result struct {
+	Steps    map[string]*StepResult
+	Duration time.Duration
+}
+
+// Succeeded is true if every step succeeded.
+func (r *Result) Succeeded() bool {
+	for _, step := range r.Steps {
+		if step.Status != Succeeded {
+			return false
+		}
+	}
+
+	return true
+}
+
+type Config struct {
+	// Concurrency bounds the steps running at once; zero means no bound.
+	Concurrency int
+
+	// Output, if set, is called with every line a step prints.
+	Output func(step string, line logs.Line)
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+type Runner struct {
+	client gordon.Client
+	config Config
+}
+
+func New(client gordon.Client, config Config) *Runner {
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	return &Runner{
+		client: client,
+		config: config,
+	}
+}
+
+// Run starts each step once its dependencies have succeeded, running
+// independent steps concurrently. Steps whose dependencies didn't succeed
+// are skipped; the rest of the job carries on. An error is returned only if
+// the job is invalid.
+func (r *Runner) Run(job Job) (*Result, error) {
+	err := job.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	started := r.config.Now()
+
+	var slots chan struct{}
+	if r.config.Concurrency > 0 {
+		slots = make(chan struct{}, r.config.Concurrency)
+	}
+
+	results := map[string]*StepResult{}
+	done := map[string]chan struct{}{}
+
+	for _, step := range job.Steps {
+		handle := step.Handle
+		if handle == "" {
+			handle = job.Handle
+		}
+
+		results[step.Name] = &StepResult{Name: step.Name, Handle: handle}
+		done[step.Name] = make(chan struct{})
+	}
+
+	wg := new(sync.WaitGroup)
+
+	for _, step := range job.Steps {
+		wg.Add(1)
+
+		go func(step Step) {
+			defer wg.Done()
+			defer close(done[step.Name])
+
+			result := results[step.Name]
+
+			for _, dependency := range step.DependsOn {
+				<-done[dependency]
+
+				if results[dependency].Status != Succeeded {
+					result.Status = Skipped
+					return
+				}
+			}
+
+			if slots != nil {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+
+			r.runStep(step, result)
+		}(step)
+	}
+
+	wg.Wait()
+
+	return &Result{
+		Steps:    results,
+		Duration: r.config.Now().Sub(started),
+	}, nil
+}
+
+func (r *Runner) runStep(step Step, result *StepResult) {
+	result.Started = r.config.Now()
+
+	defer func() {
+		result.Duration = r.config.Now().Sub(result.Started)
+	}()
+
+	process, err := gordon.StartProcess(r.client, result.Handle, gordon.RunSpec{
+		Script:               step.Script,
+		EnvironmentVariables: step.EnvironmentVariables,
+		ResourceLimits:       step.ResourceLimits,
+	})
+	if err != nil {
+		result.Status = Errored
+		result.Err = err
+		return
+	}
+
+	result.ProcessID = process.ID
+
+	sinks := []logs.Sink{}
+	if r.config.Output != nil {
+		sinks = append(sinks, logs.SinkFunc(func(line logs.Line) error {
+			r.config.Output(step.Name, line)
+			return nil
+		}))
+	}
+
+	lines := logs.NewStream(result.Handle, process.ID, sinks...)
+
+	exited := make(chan exit, 1)
+	go func() {
+		status, err := lines.Follow(process.Stream.Payloads)
+		exited <- exit{status, err}
+	}()
+
+	var timeout <-chan time.Time
+	if step.Timeout > 0 {
+		timer := time.NewTimer(step.Timeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	select {
+	case exit := <-exited:
+		result.ExitStatus = exit.status
+
+		switch {
+		case exit.err != nil:
+			result.Status = Errored
+			result.Err = exit.err
+		case exit.status != 0:
+			result.Status = Failed
+		default:
+			result.Status = Succeeded
+		}
+
+	case <-timeout:
+		result.Status = TimedOut
+		result.Err = process.Kill()
+
+		process.Stream.Detach()
+	}
+}
+
+type exit struct {
+	status uint32
+	err    error
+}
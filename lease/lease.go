@@ -0,0 +1,225 @@
+// Package lease creates containers that are destroyed unless their owner
+// keeps renewing them.
+//
+// Warden properties can only be set when a container is created, so the
+// initial expiry is stamped as a property and renewals are recorded in a
+// Store shared by the Manager and the Sweeper.
+package lease
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon"
+)
+
+// ExpiryProperty holds the RFC 3339 time the lease was first due to lapse.
+const ExpiryProperty = "lease.expires_at"
+
+// ErrNoStore is returned by NewManager and NewSweeper when no Store is given.
+// Each needs the same Store, or the Sweeper never sees the renewals.
+var ErrNoStore = errors.New("a store shared by the lease manager and sweeper is required")
+
+// ErrNoTTL is returned by NewManager when the TTL isn't positive.
+var ErrNoTTL = errors.New("a lease TTL is required")
+
+// Store records lease renewals.
+type Store interface {
+	Renew(handle string, expiry time.Time) error
+	Expiry(handle string) (time.Time, bool, error)
+	Forget(handle string) error
+}
+
+type memoryStore struct {
+	expiries map[string]time.Time
+	lock     sync.RWMutex
+}
+
+// NewMemoryStore returns a Store for a Manager and Sweeper in one process.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		expiries: map[string]time.Time{},
+	}
+}
+
+func (s *memoryStore) Renew(handle string, expiry time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expiries[handle] = expiry
+
+	return nil
+}
+
+func (s *memoryStore) Expiry(handle string) (time.Time, bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	expiry, found := s.expiries[handle]
+
+	return expiry, found, nil
+}
+
+func (s *memoryStore) Forget(handle string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.expiries, handle)
+
+	return nil
+}
+
+type Config struct {
+	// Properties identify the owner; they're added to every container.
+	Properties map[string]string
+
+	TTL time.Duration
+
+	// RenewInterval defaults to a third of the TTL, so that a missed renewal
+	// doesn't lose the lease.
+	RenewInterval time.Duration
+
+	Store Store
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+type Manager struct {
+	client gordon.Client
+	config Config
+
+	handles map[string]bool
+	lock    sync.Mutex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewManager(client gordon.Client, config Config) (*Manager, error) {
+	if config.Store == nil {
+		return nil, ErrNoStore
+	}
+
+	if config.TTL <= 0 {
+		return nil, ErrNoTTL
+	}
+
+	if config.RenewInterval <= 0 {
+		config.RenewInterval = defaultRenewInterval(config.TTL)
+	}
+
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	return &Manager{
+		client: client,
+		config: config,
+
+		handles: map[string]bool{},
+
+		stop: make(chan struct{}),
+	}, nil
+}
+
+// Create creates a container carrying the owner's properties and a lease
+// that the manager renews until the handle is released.
+func (m *Manager) Create(properties map[string]string) (*gordon.Container, error) {
+	expiry := m.config.Now().Add(m.config.TTL)
+
+	leased := map[string]string{}
+	for key, val := range properties {
+		leased[key] = val
+	}
+
+	for key, val := range m.config.Properties {
+		leased[key] = val
+	}
+
+	leased[ExpiryProperty] = expiry.UTC().Format(time.RFC3339)
+
+	container, err := gordon.CreateContainer(m.client, leased)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	m.handles[container.Handle()] = true
+	m.lock.Unlock()
+
+	return container, nil
+}
+
+// Release stops renewing the handle's lease, so that it lapses after the
+// TTL.
+func (m *Manager) Release(handle string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.handles, handle)
+}
+
+// Renew extends the lease of every held handle by the TTL.
+func (m *Manager) Renew() error {
+	m.lock.Lock()
+	handles := []string{}
+	for handle := range m.handles {
+		handles = append(handles, handle)
+	}
+	m.lock.Unlock()
+
+	expiry := m.config.Now().Add(m.config.TTL)
+
+	for _, handle := range handles {
+		err := m.config.Store.Renew(handle, expiry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func defaultRenewInterval(ttl time.Duration) time.Duration {
+	if ttl < 3 {
+		return ttl
+	}
+
+	return ttl / 3
+}
+
+// Start renews leases every RenewInterval until Stop is called. Renewal
+// errors are sent on the returned channel, if anyone is listening.
+func (m *Manager) Start() <-chan error {
+	errs := make(chan error)
+
+	go func() {
+		ticker := time.NewTicker(m.config.RenewInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				err := m.Renew()
+				if err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+				}
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+
+	return errs
+}
+
+func (m *Manager) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+}
@@ -0,0 +1,239 @@
+package lease_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/cloudfoundry-incubator/gordon/lease"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("Leases", func() {
+	var (
+		fakeGordon *fake_gordon.FakeGordon
+		store      Store
+		now        time.Time
+		clock      func() time.Time
+
+		manager *Manager
+		sweeper *Sweeper
+	)
+
+	BeforeEach(func() {
+		fakeGordon = fake_gordon.New()
+		store = NewMemoryStore()
+
+		now = time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC)
+		clock = func() time.Time { return now }
+
+		var err error
+
+		manager, err = NewManager(fakeGordon, Config{
+			Properties:    map[string]string{"owner": "executor"},
+			TTL:           time.Minute,
+			RenewInterval: 10 * time.Millisecond,
+			Store:         store,
+			Now:           clock,
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		sweeper, err = NewSweeper(fakeGordon, SweeperConfig{
+			Properties: map[string]string{"owner": "executor"},
+			Store:      store,
+			Kill:       true,
+			Now:        clock,
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		fakeGordon.WhenListing(func(filter map[string]string) (*warden.ListResponse, error) {
+			Ω(filter).Should(Equal(map[string]string{"owner": "executor"}))
+			return &warden.ListResponse{Handles: fakeGordon.CreatedHandles()}, nil
+		})
+
+		fakeGordon.WhenGettingInfo(func(handle string) (*warden.InfoResponse, error) {
+			properties := fakeGordon.CreatedProperties(handle)
+			if properties == nil {
+				return nil, errors.New("unknown handle")
+			}
+
+			info := &warden.InfoResponse{}
+			for key, val := range properties {
+				info.Properties = append(info.Properties, &warden.Property{
+					Key:   proto.String(key),
+					Value: proto.String(val),
+				})
+			}
+
+			return info, nil
+		})
+	})
+
+	It("should require a store", func() {
+		_, err := NewManager(fakeGordon, Config{TTL: time.Minute})
+		Ω(err).Should(Equal(ErrNoStore))
+
+		_, err = NewSweeper(fakeGordon, SweeperConfig{})
+		Ω(err).Should(Equal(ErrNoStore))
+	})
+
+	It("should require a TTL", func() {
+		_, err := NewManager(fakeGordon, Config{Store: store})
+		Ω(err).Should(Equal(ErrNoTTL))
+	})
+
+	It("should default the renew interval", func() {
+		defaulted, err := NewManager(fakeGordon, Config{TTL: time.Minute, Store: store})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		defaulted.Start()
+		defaulted.Stop()
+	})
+
+	Describe("creating a container", func() {
+		It("should stamp the owner's properties and the lease expiry", func() {
+			container, err := manager.Create(map[string]string{"app": "web"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(fakeGordon.CreatedProperties(container.Handle())).Should(Equal(map[string]string{
+				"app":          "web",
+				"owner":        "executor",
+				ExpiryProperty: "2014-03-01T12:01:00Z",
+			}))
+		})
+
+		It("should return errors from creating", func() {
+			fakeGordon.CreateError = errors.New("oh no")
+
+			_, err := manager.Create(nil)
+			Ω(err).Should(Equal(errors.New("oh no")))
+		})
+	})
+
+	Describe("sweeping", func() {
+		var handle string
+
+		BeforeEach(func() {
+			container, err := manager.Create(nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			handle = container.Handle()
+		})
+
+		It("should leave containers with live leases alone", func() {
+			now = now.Add(30 * time.Second)
+
+			report, err := sweeper.Sweep()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(report.Swept).Should(BeEmpty())
+
+			Ω(fakeGordon.StoppedHandles()).Should(BeEmpty())
+			Ω(fakeGordon.DestroyedHandles()).Should(BeEmpty())
+		})
+
+		It("should stop and destroy containers whose lease lapsed", func() {
+			now = now.Add(2 * time.Minute)
+
+			report, err := sweeper.Sweep()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(report.Swept).Should(Equal([]string{handle}))
+
+			Ω(fakeGordon.StoppedHandles()).Should(Equal([]string{handle}))
+			Ω(fakeGordon.DestroyedHandles()).Should(Equal([]string{handle}))
+		})
+
+		It("should honor renewals", func() {
+			now = now.Add(50 * time.Second)
+			Ω(manager.Renew()).Should(Succeed())
+
+			now = now.Add(50 * time.Second)
+
+			report, err := sweeper.Sweep()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(report.Swept).Should(BeEmpty())
+		})
+
+		It("should let released leases lapse", func() {
+			manager.Release(handle)
+
+			now = now.Add(50 * time.Second)
+			Ω(manager.Renew()).Should(Succeed())
+
+			now = now.Add(50 * time.Second)
+
+			report, err := sweeper.Sweep()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(report.Swept).Should(Equal([]string{handle}))
+		})
+
+		It("should report errors from stopping", func() {
+			fakeGordon.StopError = errors.New("oh no")
+
+			now = now.Add(2 * time.Minute)
+
+			report, err := sweeper.Sweep()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(report.Swept).Should(BeEmpty())
+			Ω(report.Errors).Should(Equal(map[string]error{handle: errors.New("oh no")}))
+
+			Ω(fakeGordon.DestroyedHandles()).Should(BeEmpty())
+		})
+
+		It("should report errors from listing", func() {
+			fakeGordon.WhenListing(func(map[string]string) (*warden.ListResponse, error) {
+				return nil, errors.New("oh no")
+			})
+
+			_, err := sweeper.Sweep()
+			Ω(err).Should(Equal(errors.New("oh no")))
+		})
+
+		Context("when a container vanishes before it's inspected", func() {
+			var later string
+
+			BeforeEach(func() {
+				container, err := manager.Create(nil)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				later = container.Handle()
+
+				fakeGordon.WhenListing(func(map[string]string) (*warden.ListResponse, error) {
+					return &warden.ListResponse{
+						Handles: []string{"vanished", handle, later},
+					}, nil
+				})
+			})
+
+			It("should carry on sweeping the rest", func() {
+				now = now.Add(2 * time.Minute)
+
+				report, err := sweeper.Sweep()
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(report.Swept).Should(Equal([]string{handle, later}))
+				Ω(report.Errors).Should(Equal(map[string]error{"vanished": errors.New("unknown handle")}))
+			})
+		})
+	})
+
+	Describe("renewing in the background", func() {
+		It("should renew every interval until stopped", func() {
+			container, err := manager.Create(nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			now = now.Add(time.Hour)
+
+			manager.Start()
+			defer manager.Stop()
+
+			Eventually(func() time.Time {
+				expiry, _, _ := store.Expiry(container.Handle())
+				return expiry
+			}).Should(Equal(now.Add(time.Minute)))
+		})
+	})
+})
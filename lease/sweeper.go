@@ -0,0 +1,127 @@
+package lease
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon"
+)
+
+type SweeperConfig struct {
+	// Properties select the containers to sweep, typically the same owner
+	// properties given to the Manager.
+	Properties map[string]string
+
+	// Store must be the one the Manager renews leases in.
+	Store Store
+
+	// Kill is passed to Stop before the container is destroyed.
+	Kill bool
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+type SweepReport struct {
+	Swept []string
+
+	// Errors maps handles to whatever went wrong inspecting, stopping or
+	// destroying them; the sweep carries on with the rest.
+	Errors map[string]error
+}
+
+type Sweeper struct {
+	client gordon.Client
+	config SweeperConfig
+}
+
+func NewSweeper(client gordon.Client, config SweeperConfig) (*Sweeper, error) {
+	if config.Store == nil {
+		return nil, ErrNoStore
+	}
+
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	return &Sweeper{
+		client: client,
+		config: config,
+	}, nil
+}
+
+// Sweep stops and destroys every leased container whose lease has lapsed.
+// Containers without a lease are left alone. An error is returned only if
+// the containers couldn't be listed.
+func (s *Sweeper) Sweep() (*SweepReport, error) {
+	containers, err := gordon.ListContainers(s.client, s.config.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SweepReport{
+		Swept:  []string{},
+		Errors: map[string]error{},
+	}
+
+	for _, container := range containers {
+		handle := container.Handle()
+
+		expiry, leased, err := s.expiry(container)
+		if err != nil {
+			report.Errors[handle] = err
+			continue
+		}
+
+		if !leased || !s.config.Now().After(expiry) {
+			continue
+		}
+
+		_, err = container.Stop(false, s.config.Kill)
+		if err != nil {
+			report.Errors[handle] = err
+			continue
+		}
+
+		_, err = container.Destroy()
+		if err != nil {
+			report.Errors[handle] = err
+			continue
+		}
+
+		s.config.Store.Forget(handle)
+
+		report.Swept = append(report.Swept, handle)
+	}
+
+	return report, nil
+}
+
+// the later of the stamped expiry and the last renewal
+func (s *Sweeper) expiry(container *gordon.Container) (time.Time, bool, error) {
+	properties, err := container.Properties()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	stamped, found := properties[ExpiryProperty]
+	if !found {
+		return time.Time{}, false, nil
+	}
+
+	expiry, err := time.Parse(time.RFC3339, stamped)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("container %s has an invalid lease %q: %s", container.Handle(), stamped, err)
+	}
+
+	renewed, found, err := s.config.Store.Expiry(container.Handle())
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	if found && renewed.After(expiry) {
+		expiry = renewed
+	}
+
+	return expiry, true, nil
+}
@@ -0,0 +1,242 @@
+package logs_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon"
+	. "github.com/cloudfoundry-incubator/gordon/logs"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("Streaming lines", func() {
+	var (
+		stream *Stream
+		lines  []Line
+		now    time.Time
+	)
+
+	stdout := warden.ProcessPayload_stdout
+	stderr := warden.ProcessPayload_stderr
+
+	chunk := func(source warden.ProcessPayload_Source, data string) *warden.ProcessPayload {
+		return &warden.ProcessPayload{Source: &source, Data: proto.String(data)}
+	}
+
+	BeforeEach(func() {
+		lines = nil
+		now = time.Unix(1400000000, 0)
+
+		stream = NewStream("some-handle", 42, SinkFunc(func(line Line) error {
+			lines = append(lines, line)
+			return nil
+		}))
+
+		stream.Now = func() time.Time {
+			return now
+		}
+	})
+
+	It("reassembles lines split across payloads", func() {
+		Ω(stream.Write(chunk(stdout, "hel"))).Should(Succeed())
+		Ω(lines).Should(BeEmpty())
+
+		Ω(stream.Write(chunk(stdout, "lo\nwor"))).Should(Succeed())
+		Ω(stream.Write(chunk(stdout, "ld\n"))).Should(Succeed())
+
+		Ω(lines).Should(HaveLen(2))
+		Ω(lines[0].Text).Should(Equal("hello"))
+		Ω(lines[1].Text).Should(Equal("world"))
+	})
+
+	It("emits every line in a payload", func() {
+		Ω(stream.Write(chunk(stdout, "a\n\nb\n"))).Should(Succeed())
+
+		Ω(lines).Should(HaveLen(3))
+		Ω(lines[0].Text).Should(Equal("a"))
+		Ω(lines[1].Text).Should(Equal(""))
+		Ω(lines[2].Text).Should(Equal("b"))
+	})
+
+	It("keeps stdout and stderr apart", func() {
+		Ω(stream.Write(chunk(stdout, "out-"))).Should(Succeed())
+		Ω(stream.Write(chunk(stderr, "err-"))).Should(Succeed())
+		Ω(stream.Write(chunk(stderr, "line\n"))).Should(Succeed())
+		Ω(stream.Write(chunk(stdout, "line\n"))).Should(Succeed())
+
+		Ω(lines).Should(Equal([]Line{
+			{Time: now, Handle: "some-handle", ProcessID: 42, Source: stderr, Text: "err-line"},
+			{Time: now, Handle: "some-handle", ProcessID: 42, Source: stdout, Text: "out-line"},
+		}))
+	})
+
+	It("stamps lines with the time their first chunk arrived", func() {
+		started := now
+
+		Ω(stream.Write(chunk(stdout, "slow"))).Should(Succeed())
+
+		now = now.Add(time.Second)
+		Ω(stream.Write(chunk(stdout, " line\nfast"))).Should(Succeed())
+
+		now = now.Add(time.Second)
+		Ω(stream.Write(chunk(stdout, " line\n"))).Should(Succeed())
+
+		Ω(lines).Should(HaveLen(2))
+		Ω(lines[0].Time).Should(Equal(started))
+		Ω(lines[1].Time).Should(Equal(started.Add(time.Second)))
+	})
+
+	It("sends partial lines on Flush", func() {
+		Ω(stream.Write(chunk(stdout, "no newline"))).Should(Succeed())
+		Ω(lines).Should(BeEmpty())
+
+		Ω(stream.Flush()).Should(Succeed())
+		Ω(lines).Should(HaveLen(1))
+		Ω(lines[0].Text).Should(Equal("no newline"))
+
+		Ω(stream.Flush()).Should(Succeed())
+		Ω(lines).Should(HaveLen(1))
+	})
+
+	It("fans lines out to every sink", func() {
+		first := &bytes.Buffer{}
+		second := &bytes.Buffer{}
+
+		stream := NewStream("some-handle", 42, WriterSink(first, Format{}), WriterSink(second, Format{}))
+
+		Ω(stream.Write(chunk(stdout, "hello\n"))).Should(Succeed())
+
+		Ω(first.String()).Should(Equal("hello\n"))
+		Ω(second.String()).Should(Equal("hello\n"))
+	})
+
+	Context("when a sink fails", func() {
+		disaster := errors.New("oh no!")
+
+		It("still sends the line to the other sinks and returns the error", func() {
+			delivered := &bytes.Buffer{}
+
+			stream := NewStream(
+				"some-handle",
+				42,
+				SinkFunc(func(Line) error { return disaster }),
+				WriterSink(delivered, Format{}),
+			)
+
+			Ω(stream.Write(chunk(stdout, "hello\n"))).Should(Equal(disaster))
+			Ω(delivered.String()).Should(Equal("hello\n"))
+		})
+	})
+
+	Describe("Follow", func() {
+		It("writes payloads until the exit status and returns it", func() {
+			payloads := make(chan *warden.ProcessPayload, 3)
+			payloads <- chunk(stdout, "hello\n")
+			payloads <- chunk(stderr, "partial")
+			payloads <- &warden.ProcessPayload{ExitStatus: proto.Uint32(3)}
+
+			status, err := stream.Follow(payloads)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(status).Should(Equal(uint32(3)))
+
+			Ω(lines).Should(HaveLen(2))
+			Ω(lines[0].Text).Should(Equal("hello"))
+			Ω(lines[1].Text).Should(Equal("partial"))
+		})
+
+		It("returns ErrNoExitStatus if the payloads end without one", func() {
+			payloads := make(chan *warden.ProcessPayload, 1)
+			payloads <- chunk(stdout, "hello")
+			close(payloads)
+
+			_, err := stream.Follow(payloads)
+			Ω(err).Should(Equal(gordon.ErrNoExitStatus))
+
+			Ω(lines).Should(HaveLen(1))
+		})
+	})
+})
+
+var _ = Describe("Format", func() {
+	line := Line{
+		Time:      time.Date(2014, 5, 13, 16, 26, 40, 500000000, time.UTC),
+		Handle:    "some-handle",
+		ProcessID: 42,
+		Source:    warden.ProcessPayload_stderr,
+		Text:      "hello",
+	}
+
+	It("renders just the text by default", func() {
+		Ω(Format{}.String(line)).Should(Equal("hello"))
+	})
+
+	It("can lead with the timestamp", func() {
+		Ω(Format{Timestamps: true}.String(line)).Should(Equal("2014-05-13T16:26:40.5Z hello"))
+	})
+
+	It("can prefix the handle, process ID and source", func() {
+		Ω(Format{Prefix: true}.String(line)).Should(Equal("[some-handle/42 stderr] hello"))
+	})
+
+	It("can do both", func() {
+		Ω(Format{Timestamps: true, Prefix: true}.String(line)).Should(
+			Equal("2014-05-13T16:26:40.5Z [some-handle/42 stderr] hello"),
+		)
+	})
+})
+
+var _ = Describe("Sinks", func() {
+	stdoutLine := Line{Source: warden.ProcessPayload_stdout, Text: "out"}
+	stderrLine := Line{Source: warden.ProcessPayload_stderr, Text: "err"}
+
+	Describe("SplitSink", func() {
+		It("routes stdout and stderr lines to different sinks", func() {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			sink := SplitSink(WriterSink(stdout, Format{}), WriterSink(stderr, Format{}))
+
+			Ω(sink.Line(stdoutLine)).Should(Succeed())
+			Ω(sink.Line(stderrLine)).Should(Succeed())
+
+			Ω(stdout.String()).Should(Equal("out\n"))
+			Ω(stderr.String()).Should(Equal("err\n"))
+		})
+	})
+
+	Describe("FileSink", func() {
+		var path string
+
+		BeforeEach(func() {
+			dir, err := ioutil.TempDir("", "logs")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			path = filepath.Join(dir, "process.log")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(filepath.Dir(path))
+		})
+
+		It("appends formatted lines to the file", func() {
+			Ω(ioutil.WriteFile(path, []byte("existing\n"), 0644)).Should(Succeed())
+
+			sink, err := OpenFileSink(path, Format{})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(sink.Line(stdoutLine)).Should(Succeed())
+			Ω(sink.Line(stderrLine)).Should(Succeed())
+			Ω(sink.Close()).Should(Succeed())
+
+			Ω(ioutil.ReadFile(path)).Should(Equal([]byte("existing\nout\nerr\n")))
+		})
+	})
+})
@@ -0,0 +1,74 @@
+package logs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+type Sink interface {
+	Line(line Line) error
+}
+
+type SinkFunc func(line Line) error
+
+func (f SinkFunc) Line(line Line) error {
+	return f(line)
+}
+
+type writerSink struct {
+	writer io.Writer
+	format Format
+	lock   sync.Mutex
+}
+
+// WriterSink writes each line, formatted and newline-terminated, to w.
+// Writes are serialized so the sink can be shared between streams.
+func WriterSink(w io.Writer, format Format) Sink {
+	return &writerSink{writer: w, format: format}
+}
+
+func (s *writerSink) Line(line Line) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, err := fmt.Fprintln(s.writer, s.format.String(line))
+	return err
+}
+
+// SplitSink sends stdout lines to one sink and everything else to another.
+func SplitSink(stdout, stderr Sink) Sink {
+	return SinkFunc(func(line Line) error {
+		if line.Source == warden.ProcessPayload_stdout {
+			return stdout.Line(line)
+		}
+
+		return stderr.Line(line)
+	})
+}
+
+type FileSink struct {
+	Sink
+
+	file *os.File
+}
+
+// OpenFileSink appends lines to the file at path, creating it if needed.
+func OpenFileSink(path string, format Format) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{
+		Sink: WriterSink(file, format),
+		file: file,
+	}, nil
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,198 @@
+// Package logs turns a process's output payloads into whole, timestamped
+// lines and fans them out to sinks.
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+	"github.com/cloudfoundry-incubator/gordon"
+)
+
+type Line struct {
+	// Time is when the first chunk of the line was received.
+	Time time.Time
+
+	Handle    string
+	ProcessID uint32
+	Source    warden.ProcessPayload_Source
+
+	// Text is the line without its trailing newline.
+	Text string
+}
+
+// Format renders a line as text. With Timestamps it leads with the receive
+// time, and with Prefix it names the handle, process ID and source.
+type Format struct {
+	Timestamps bool
+	Prefix     bool
+}
+
+func (f Format) String(line Line) string {
+	buf := &bytes.Buffer{}
+
+	if f.Timestamps {
+		buf.WriteString(line.Time.UTC().Format(time.RFC3339Nano))
+		buf.WriteString(" ")
+	}
+
+	if f.Prefix {
+		fmt.Fprintf(buf, "[%s/%d %s] ", line.Handle, line.ProcessID, sourceName(line.Source))
+	}
+
+	buf.WriteString(line.Text)
+
+	return buf.String()
+}
+
+type Stream struct {
+	handle    string
+	processID uint32
+	sinks     []Sink
+
+	partial map[warden.ProcessPayload_Source]*partialLine
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+type partialLine struct {
+	started time.Time
+	text    []byte
+}
+
+func NewStream(handle string, processID uint32, sinks ...Sink) *Stream {
+	return &Stream{
+		handle:    handle,
+		processID: processID,
+		sinks:     sinks,
+
+		partial: map[warden.ProcessPayload_Source]*partialLine{},
+
+		Now: time.Now,
+	}
+}
+
+// Write splits a payload's data into lines, sending each complete one to
+// every sink and holding back any trailing partial line until the rest of it
+// arrives. Payloads without data, such as the exit status, are ignored.
+func (s *Stream) Write(payload *warden.ProcessPayload) error {
+	if payload.Data == nil {
+		return nil
+	}
+
+	source := payload.GetSource()
+	data := []byte(payload.GetData())
+	now := s.Now()
+
+	var err error
+
+	for len(data) > 0 {
+		partial, found := s.partial[source]
+		if !found {
+			partial = &partialLine{started: now}
+			s.partial[source] = partial
+		}
+
+		newline := bytes.IndexByte(data, '\n')
+		if newline == -1 {
+			partial.text = append(partial.text, data...)
+			break
+		}
+
+		partial.text = append(partial.text, data[:newline]...)
+		data = data[newline+1:]
+
+		delete(s.partial, source)
+
+		sendErr := s.send(source, partial)
+		if err == nil {
+			err = sendErr
+		}
+	}
+
+	return err
+}
+
+// Flush sends any partial lines, e.g. output not ending in a newline.
+func (s *Stream) Flush() error {
+	var err error
+
+	for _, source := range []warden.ProcessPayload_Source{
+		warden.ProcessPayload_stdout,
+		warden.ProcessPayload_stderr,
+	} {
+		partial, found := s.partial[source]
+		if !found {
+			continue
+		}
+
+		delete(s.partial, source)
+
+		sendErr := s.send(source, partial)
+		if err == nil {
+			err = sendErr
+		}
+	}
+
+	return err
+}
+
+// Follow writes payloads until the process exits or the channel is closed,
+// then flushes. It returns the exit status, or gordon.ErrNoExitStatus if the
+// channel closed first.
+func (s *Stream) Follow(payloads <-chan *warden.ProcessPayload) (uint32, error) {
+	for payload := range payloads {
+		if payload.ExitStatus != nil {
+			return payload.GetExitStatus(), s.Flush()
+		}
+
+		err := s.Write(payload)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err := s.Flush()
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, gordon.ErrNoExitStatus
+}
+
+func (s *Stream) send(source warden.ProcessPayload_Source, partial *partialLine) error {
+	line := Line{
+		Time:      partial.started,
+		Handle:    s.handle,
+		ProcessID: s.processID,
+		Source:    source,
+		Text:      string(partial.text),
+	}
+
+	var err error
+
+	for _, sink := range s.sinks {
+		sinkErr := sink.Line(line)
+		if err == nil {
+			err = sinkErr
+		}
+	}
+
+	return err
+}
+
+func sourceName(source warden.ProcessPayload_Source) string {
+	switch source {
+	case warden.ProcessPayload_stdout:
+		return "stdout"
+	case warden.ProcessPayload_stderr:
+		return "stderr"
+	case warden.ProcessPayload_stdin:
+		return "stdin"
+	default:
+		return fmt.Sprintf("source-%d", source)
+	}
+}
@@ -0,0 +1,257 @@
+package policy
+
+import (
+	"sync"
+	"time"
+
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+	"github.com/cloudfoundry-incubator/gordon"
+)
+
+// Drift is a running container whose limit is outside of its class's
+// range. A Value of 0 means the container is unlimited.
+type Drift struct {
+	Handle string
+	Class  string
+	Limit  string
+	Value  uint64
+}
+
+// AuditReport lists the containers that drifted.
+type AuditReport struct {
+	Drifts []Drift
+
+	// Errors maps handles to whatever went wrong reading their limits, for
+	// example because they were destroyed mid-audit; the audit carries on
+	// with the rest.
+	Errors map[string]error
+}
+
+// Enforcer validates limits against a policy before they reach warden.
+// Containers that don't belong to any class are not restricted.
+type Enforcer struct {
+	client gordon.Client
+	policy Policy
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewEnforcer(client gordon.Client, policy Policy) *Enforcer {
+	return &Enforcer{
+		client: client,
+		policy: policy,
+
+		stop: make(chan struct{}),
+	}
+}
+
+// Create creates a container and applies its class's default limits. If
+// that fails, the container is destroyed.
+func (e *Enforcer) Create(properties map[string]string) (*gordon.Container, error) {
+	container, err := gordon.CreateContainer(e.client, properties)
+	if err != nil {
+		return nil, err
+	}
+
+	err = e.applyDefaults(container, properties)
+	if err != nil {
+		container.Destroy()
+		return nil, err
+	}
+
+	return container, nil
+}
+
+func (e *Enforcer) applyDefaults(container *gordon.Container, properties map[string]string) error {
+	class, found := e.policy.ClassFor(properties)
+	if !found {
+		return nil
+	}
+
+	if class.Memory.Default != 0 {
+		_, err := container.LimitMemory(class.Memory.Default)
+		if err != nil {
+			return err
+		}
+	}
+
+	if class.CPU.Default != 0 {
+		_, err := container.LimitCPU(class.CPU.Default)
+		if err != nil {
+			return err
+		}
+	}
+
+	if class.Disk.Default != 0 {
+		_, err := container.LimitDisk(gordon.DiskLimits{ByteLimit: class.Disk.Default})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Enforcer) LimitMemory(handle string, limit uint64) (*warden.LimitMemoryResponse, error) {
+	err := e.check(handle, MemoryLimit, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.client.LimitMemory(handle, limit)
+}
+
+func (e *Enforcer) LimitCPU(handle string, limitInShares uint64) (*warden.LimitCpuResponse, error) {
+	err := e.check(handle, CPULimit, limitInShares)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.client.LimitCPU(handle, limitInShares)
+}
+
+// LimitDisk checks every byte limit that's set against the class's disk
+// range; block and inode limits aren't covered by the policy.
+func (e *Enforcer) LimitDisk(handle string, limits gordon.DiskLimits) (*warden.LimitDiskResponse, error) {
+	byteLimits := []uint64{}
+
+	for _, value := range []uint64{limits.ByteLimit, limits.Byte, limits.ByteSoft, limits.ByteHard} {
+		if value != 0 {
+			byteLimits = append(byteLimits, value)
+		}
+	}
+
+	err := e.check(handle, DiskLimit, byteLimits...)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.client.LimitDisk(handle, limits)
+}
+
+func (e *Enforcer) check(handle string, limit string, values ...uint64) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	info, err := e.client.GetInfo(handle)
+	if err != nil {
+		return err
+	}
+
+	class, found := e.policy.ClassFor(info.Properties)
+	if !found {
+		return nil
+	}
+
+	for _, value := range values {
+		err := class.check(limit, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Audit reads the limits of every container in a class and reports those
+// outside of the class's range. It only fails if listing containers does.
+func (e *Enforcer) Audit() (*AuditReport, error) {
+	report := &AuditReport{
+		Drifts: []Drift{},
+		Errors: map[string]error{},
+	}
+
+	seen := map[string]bool{}
+
+	for _, class := range e.policy.Classes {
+		handles, err := e.client.GetHandles(class.Properties)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, handle := range handles {
+			// an earlier class claimed it
+			if seen[handle] {
+				continue
+			}
+
+			seen[handle] = true
+
+			limits, err := e.limits(handle)
+			if err != nil {
+				report.Errors[handle] = err
+				continue
+			}
+
+			for _, limit := range []string{MemoryLimit, CPULimit, DiskLimit} {
+				if class.drifted(limit, limits[limit]) {
+					report.Drifts = append(report.Drifts, Drift{
+						Handle: handle,
+						Class:  class.Name,
+						Limit:  limit,
+						Value:  limits[limit],
+					})
+				}
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func (e *Enforcer) limits(handle string) (map[string]uint64, error) {
+	memory, err := e.client.GetMemoryLimit(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	cpu, err := e.client.GetCPULimit(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	disk, err := e.client.GetDiskLimit(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]uint64{
+		MemoryLimit: memory,
+		CPULimit:    cpu,
+		DiskLimit:   disk,
+	}, nil
+}
+
+// DefaultAuditInterval is used when StartAuditing is given an interval of
+// zero or less.
+const DefaultAuditInterval = time.Minute
+
+// StartAuditing audits every interval until Stop is called, passing each
+// result to report.
+func (e *Enforcer) StartAuditing(interval time.Duration, report func(*AuditReport, error)) {
+	if interval <= 0 {
+		interval = DefaultAuditInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				report(e.Audit())
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (e *Enforcer) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+	})
+}
@@ -0,0 +1,126 @@
+// Package policy declares per-class bounds and defaults for container
+// limits, and enforces them on top of a gordon.Client.
+package policy
+
+import (
+	"fmt"
+)
+
+const (
+	MemoryLimit = "memory"
+	CPULimit    = "cpu"
+	DiskLimit   = "disk"
+)
+
+// Range bounds a limit. A zero Max means there is no upper bound, and a zero
+// Default means no limit is applied after creating a container.
+type Range struct {
+	Min     uint64
+	Max     uint64
+	Default uint64
+}
+
+func (r Range) allows(value uint64) bool {
+	return value >= r.Min && (r.Max == 0 || value <= r.Max)
+}
+
+// Class is a kind of container, identified by its properties.
+type Class struct {
+	Name string
+
+	// Properties that a container must have, all of them, to be in the class.
+	Properties map[string]string
+
+	Memory Range
+	CPU    Range
+	Disk   Range
+}
+
+func (c Class) matches(properties map[string]string) bool {
+	for key, val := range c.Properties {
+		if properties[key] != val {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c Class) ranges() map[string]Range {
+	return map[string]Range{
+		MemoryLimit: c.Memory,
+		CPULimit:    c.CPU,
+		DiskLimit:   c.Disk,
+	}
+}
+
+// Policy is a list of classes; a container belongs to the first one it
+// matches.
+type Policy struct {
+	Classes []Class
+}
+
+func (p Policy) ClassFor(properties map[string]string) (Class, bool) {
+	for _, class := range p.Classes {
+		if class.matches(properties) {
+			return class, true
+		}
+	}
+
+	return Class{}, false
+}
+
+// Validate checks that every class's defaults are within its bounds.
+func (p Policy) Validate() error {
+	for _, class := range p.Classes {
+		for _, limit := range []string{MemoryLimit, CPULimit, DiskLimit} {
+			r := class.ranges()[limit]
+
+			if r.Max != 0 && r.Min > r.Max {
+				return fmt.Errorf("class %s: %s minimum %d is above maximum %d", class.Name, limit, r.Min, r.Max)
+			}
+
+			if r.Default != 0 && !r.allows(r.Default) {
+				return &ViolationError{Class: class.Name, Limit: limit, Value: r.Default, Range: r}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ViolationError is returned for a limit outside of its class's range.
+type ViolationError struct {
+	Class string
+	Limit string
+	Value uint64
+	Range Range
+}
+
+func (e *ViolationError) Error() string {
+	if e.Range.Max == 0 {
+		return fmt.Sprintf("%s limit %d for class %s is below the minimum of %d", e.Limit, e.Value, e.Class, e.Range.Min)
+	}
+
+	return fmt.Sprintf("%s limit %d for class %s is outside of %d-%d", e.Limit, e.Value, e.Class, e.Range.Min, e.Range.Max)
+}
+
+func (c Class) check(limit string, value uint64) error {
+	r := c.ranges()[limit]
+	if r.allows(value) {
+		return nil
+	}
+
+	return &ViolationError{Class: c.Name, Limit: limit, Value: value, Range: r}
+}
+
+// 0 is how warden reports no limit, which only satisfies an unbounded range
+func (c Class) drifted(limit string, value uint64) bool {
+	r := c.ranges()[limit]
+
+	if value == 0 {
+		return r.Max != 0
+	}
+
+	return !r.allows(value)
+}
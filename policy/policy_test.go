@@ -0,0 +1,274 @@
+package policy_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/cloudfoundry-incubator/gordon/policy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("Policy", func() {
+	var policy Policy
+
+	BeforeEach(func() {
+		policy = Policy{
+			Classes: []Class{
+				{
+					Name:       "staging",
+					Properties: map[string]string{"owner": "stager"},
+					Memory:     Range{Min: 64, Max: 1024, Default: 256},
+					CPU:        Range{Max: 16, Default: 10},
+					Disk:       Range{Min: 1024, Max: 4096},
+				},
+				{
+					Name:   "everything else",
+					Memory: Range{Min: 32},
+				},
+			},
+		}
+	})
+
+	Describe("classifying containers", func() {
+		It("should pick the first class whose properties match", func() {
+			class, found := policy.ClassFor(map[string]string{"owner": "stager", "app": "web"})
+			Ω(found).Should(BeTrue())
+			Ω(class.Name).Should(Equal("staging"))
+
+			class, found = policy.ClassFor(map[string]string{"owner": "executor"})
+			Ω(found).Should(BeTrue())
+			Ω(class.Name).Should(Equal("everything else"))
+		})
+	})
+
+	Describe("validating the policy", func() {
+		It("should accept defaults within range", func() {
+			Ω(policy.Validate()).Should(Succeed())
+		})
+
+		It("should reject defaults out of range", func() {
+			policy.Classes[0].Memory.Default = 2048
+
+			Ω(policy.Validate()).Should(Equal(&ViolationError{
+				Class: "staging",
+				Limit: MemoryLimit,
+				Value: 2048,
+				Range: Range{Min: 64, Max: 1024, Default: 2048},
+			}))
+		})
+
+		It("should reject inverted ranges", func() {
+			policy.Classes[0].Disk = Range{Min: 10, Max: 5}
+			Ω(policy.Validate()).Should(HaveOccurred())
+		})
+	})
+
+	Describe("enforcing", func() {
+		var (
+			client   *fake_gordon.FakeGordon
+			enforcer *Enforcer
+		)
+
+		BeforeEach(func() {
+			client = fake_gordon.New()
+
+			client.WhenGettingInfo(func(handle string) (*warden.InfoResponse, error) {
+				return &warden.InfoResponse{
+					Properties: []*warden.Property{
+						{Key: proto.String("owner"), Value: proto.String(handle)},
+					},
+				}, nil
+			})
+
+			enforcer = NewEnforcer(client, policy)
+		})
+
+		Describe("creating", func() {
+			It("should apply the class's defaults", func() {
+				container, err := enforcer.Create(map[string]string{"owner": "stager"})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				handle := container.Handle()
+
+				Ω(client.MemoryLimits()).Should(Equal([]fake_gordon.Limit{{Handle: handle, Limit: 256}}))
+				Ω(client.CPULimits()).Should(Equal([]fake_gordon.Limit{{Handle: handle, Limit: 10}}))
+				Ω(client.DiskLimits()).Should(BeEmpty())
+			})
+
+			It("should destroy the container if a default can't be applied", func() {
+				client.SetLimitCPUError(errors.New("oh no"))
+
+				_, err := enforcer.Create(map[string]string{"owner": "stager"})
+				Ω(err).Should(Equal(errors.New("oh no")))
+
+				Ω(client.DestroyedHandles()).Should(Equal(client.CreatedHandles()))
+			})
+		})
+
+		Describe("limiting", func() {
+			It("should pass limits within range through", func() {
+				_, err := enforcer.LimitMemory("stager", 512)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(client.MemoryLimits()).Should(Equal([]fake_gordon.Limit{{Handle: "stager", Limit: 512}}))
+			})
+
+			It("should reject limits out of range without asking warden", func() {
+				_, err := enforcer.LimitMemory("stager", 2048)
+				Ω(err).Should(BeAssignableToTypeOf(&ViolationError{}))
+
+				_, err = enforcer.LimitDisk("stager", gordon.DiskLimits{ByteLimit: 8192})
+				Ω(err).Should(BeAssignableToTypeOf(&ViolationError{}))
+
+				_, err = enforcer.LimitMemory("executor", 16)
+				Ω(err).Should(BeAssignableToTypeOf(&ViolationError{}))
+
+				Ω(client.MemoryLimits()).Should(BeEmpty())
+				Ω(client.DiskLimits()).Should(BeEmpty())
+			})
+
+			It("should check whichever byte limits are set", func() {
+				for _, limits := range []gordon.DiskLimits{
+					{Byte: 8192},
+					{ByteSoft: 512},
+					{ByteSoft: 2048, ByteHard: 8192},
+				} {
+					_, err := enforcer.LimitDisk("stager", limits)
+					Ω(err).Should(BeAssignableToTypeOf(&ViolationError{}))
+				}
+
+				Ω(client.DiskLimits()).Should(BeEmpty())
+
+				_, err := enforcer.LimitDisk("stager", gordon.DiskLimits{ByteSoft: 2048, ByteHard: 4096})
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should pass limits without a byte limit through", func() {
+				limits := gordon.DiskLimits{InodeLimit: 100, BlockHard: 10}
+
+				_, err := enforcer.LimitDisk("stager", limits)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(client.DiskLimits()).Should(Equal([]fake_gordon.DiskLimit{{Handle: "stager", Limits: limits}}))
+			})
+		})
+
+		Describe("auditing", func() {
+			BeforeEach(func() {
+				client.WhenListing(func(filter map[string]string) (*warden.ListResponse, error) {
+					if filter["owner"] == "stager" {
+						return &warden.ListResponse{Handles: []string{"stager-1", "stager-2", "stager-3"}}, nil
+					}
+
+					return &warden.ListResponse{Handles: []string{"stager-1", "stager-2", "stager-3", "other"}}, nil
+				})
+
+				client.LimitMemory("stager-1", 512)
+				client.LimitMemory("stager-2", 2048)
+				client.LimitMemory("other", 16)
+
+				client.LimitCPU("stager-2", 20)
+
+				client.LimitDisk("stager-1", gordon.DiskLimits{ByteLimit: 8192})
+			})
+
+			It("should flag containers outside of their class's range", func() {
+				report, err := enforcer.Audit()
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(report.Errors).Should(BeEmpty())
+
+				Ω(report.Drifts).Should(Equal([]Drift{
+					{Handle: "stager-1", Class: "staging", Limit: CPULimit, Value: 0},
+					{Handle: "stager-1", Class: "staging", Limit: DiskLimit, Value: 8192},
+					{Handle: "stager-2", Class: "staging", Limit: MemoryLimit, Value: 2048},
+					{Handle: "stager-2", Class: "staging", Limit: CPULimit, Value: 20},
+					{Handle: "stager-2", Class: "staging", Limit: DiskLimit, Value: 0},
+					{Handle: "stager-3", Class: "staging", Limit: MemoryLimit, Value: 0},
+					{Handle: "stager-3", Class: "staging", Limit: CPULimit, Value: 0},
+					{Handle: "stager-3", Class: "staging", Limit: DiskLimit, Value: 0},
+					{Handle: "other", Class: "everything else", Limit: MemoryLimit, Value: 16},
+				}))
+			})
+
+			Context("when a container's limits can't be read", func() {
+				BeforeEach(func() {
+					enforcer = NewEnforcer(&vanishingClient{FakeGordon: client, vanished: "stager-2"}, policy)
+				})
+
+				It("should report its error and audit the rest", func() {
+					report, err := enforcer.Audit()
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(report.Errors).Should(Equal(map[string]error{"stager-2": errors.New("unknown handle")}))
+
+					handles := map[string]bool{}
+					for _, drift := range report.Drifts {
+						handles[drift.Handle] = true
+					}
+
+					Ω(handles).Should(Equal(map[string]bool{"stager-1": true, "stager-3": true, "other": true}))
+				})
+			})
+
+			Context("when listing fails", func() {
+				BeforeEach(func() {
+					client.WhenListing(func(map[string]string) (*warden.ListResponse, error) {
+						return nil, errors.New("oh no")
+					})
+				})
+
+				It("should return the error", func() {
+					_, err := enforcer.Audit()
+					Ω(err).Should(Equal(errors.New("oh no")))
+				})
+			})
+
+			It("should audit periodically until stopped", func() {
+				reports := make(chan []Drift, 10)
+
+				enforcer.StartAuditing(10*time.Millisecond, func(report *AuditReport, err error) {
+					Ω(err).ShouldNot(HaveOccurred())
+					reports <- report.Drifts
+				})
+
+				defer enforcer.Stop()
+
+				Eventually(reports).Should(Receive(HaveLen(9)))
+			})
+
+			It("should default a non-positive audit interval", func() {
+				reports := make(chan *AuditReport, 10)
+
+				enforcer.StartAuditing(0, func(report *AuditReport, err error) {
+					reports <- report
+				})
+
+				defer enforcer.Stop()
+
+				Consistently(reports, 50*time.Millisecond).ShouldNot(Receive())
+			})
+		})
+	})
+})
+
+// vanishingClient fails to read the limits of one container, as if it was
+// destroyed mid-audit.
+type vanishingClient struct {
+	*fake_gordon.FakeGordon
+
+	vanished string
+}
+
+func (c *vanishingClient) GetCPULimit(handle string) (uint64, error) {
+	if handle == c.vanished {
+		return 0, errors.New("unknown handle")
+	}
+
+	return c.FakeGordon.GetCPULimit(handle)
+}
@@ -0,0 +1,270 @@
+// Package pool keeps containers created ahead of time, so that short-lived
+// work doesn't have to wait for warden to create one.
+package pool
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/provision"
+)
+
+var ErrPoolClosed = errors.New("pool is closed")
+
+type Config struct {
+	// Size is the number of containers kept ready.
+	Size int
+
+	// Spec is applied to every container the pool creates.
+	Spec provision.Spec
+
+	// CleanupScript, if set, is run in released containers. Those it exits
+	// zero for go back into the pool; the rest are destroyed.
+	CleanupScript string
+
+	// RetryInterval is how long to wait after failing to create a
+	// container before trying again. Defaults to one second.
+	RetryInterval time.Duration
+}
+
+type Stats struct {
+	Ready int
+
+	Hits   uint64
+	Misses uint64
+
+	Created      uint64
+	CreateErrors uint64
+	Recycled     uint64
+	Destroyed    uint64
+}
+
+type Pool struct {
+	client      gordon.Client
+	provisioner *provision.Provisioner
+	config      Config
+
+	ready   []*gordon.Container
+	stats   Stats
+	started bool
+	closed  bool
+	lock    sync.Mutex
+
+	replenish chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func New(client gordon.Client, config Config) *Pool {
+	if config.RetryInterval == 0 {
+		config.RetryInterval = time.Second
+	}
+
+	return &Pool{
+		client:      client,
+		provisioner: provision.New(client, nil, nil),
+		config:      config,
+
+		replenish: make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start fills the pool in the background, and keeps it full as containers
+// are acquired.
+func (p *Pool) Start() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.started || p.closed {
+		return
+	}
+
+	p.started = true
+
+	go p.replenishLoop()
+
+	p.signal()
+}
+
+// Acquire hands out a ready container, or creates one if the pool is
+// empty.
+func (p *Pool) Acquire() (*gordon.Container, error) {
+	p.lock.Lock()
+
+	if p.closed {
+		p.lock.Unlock()
+		return nil, ErrPoolClosed
+	}
+
+	if len(p.ready) > 0 {
+		container := p.ready[0]
+		p.ready = p.ready[1:]
+		p.stats.Hits++
+		p.lock.Unlock()
+
+		p.signal()
+
+		return container, nil
+	}
+
+	p.stats.Misses++
+	p.lock.Unlock()
+
+	p.signal()
+
+	return p.create()
+}
+
+// Release gives a container back. It is recycled if a cleanup script is
+// configured, succeeds, and the pool has room for it; otherwise it is
+// destroyed.
+func (p *Pool) Release(container *gordon.Container) error {
+	if p.config.CleanupScript != "" && p.cleanUp(container) == nil {
+		p.lock.Lock()
+
+		if !p.closed && len(p.ready) < p.config.Size {
+			p.ready = append(p.ready, container)
+			p.stats.Recycled++
+			p.lock.Unlock()
+
+			return nil
+		}
+
+		p.lock.Unlock()
+	}
+
+	return p.destroy(container)
+}
+
+func (p *Pool) Stats() Stats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := p.stats
+	stats.Ready = len(p.ready)
+
+	return stats
+}
+
+// Close stops replenishing and destroys every ready container. Acquired
+// containers are left to their holders.
+func (p *Pool) Close() error {
+	p.lock.Lock()
+
+	if p.closed {
+		p.lock.Unlock()
+		return nil
+	}
+
+	p.closed = true
+	started := p.started
+	p.lock.Unlock()
+
+	close(p.stop)
+
+	if started {
+		<-p.done
+	}
+
+	p.lock.Lock()
+	ready := p.ready
+	p.ready = nil
+	p.lock.Unlock()
+
+	var firstErr error
+
+	for _, container := range ready {
+		err := p.destroy(container)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (p *Pool) replenishLoop() {
+	defer close(p.done)
+
+	for {
+		select {
+		case <-p.replenish:
+		case <-p.stop:
+			return
+		}
+
+		for p.needsMore() {
+			container, err := p.create()
+			if err != nil {
+				select {
+				case <-time.After(p.config.RetryInterval):
+					continue
+				case <-p.stop:
+					return
+				}
+			}
+
+			p.lock.Lock()
+			p.ready = append(p.ready, container)
+			p.lock.Unlock()
+		}
+	}
+}
+
+func (p *Pool) needsMore() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return !p.closed && len(p.ready) < p.config.Size
+}
+
+func (p *Pool) signal() {
+	select {
+	case p.replenish <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pool) create() (*gordon.Container, error) {
+	result, err := p.provisioner.Apply(p.config.Spec)
+
+	p.lock.Lock()
+	if err != nil {
+		p.stats.CreateErrors++
+	} else {
+		p.stats.Created++
+	}
+	p.lock.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return gordon.NewContainer(p.client, result.Handle), nil
+}
+
+func (p *Pool) destroy(container *gordon.Container) error {
+	_, err := container.Destroy()
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	p.stats.Destroyed++
+	p.lock.Unlock()
+
+	return nil
+}
+
+func (p *Pool) cleanUp(container *gordon.Container) error {
+	_, payloads, err := container.Run(p.config.CleanupScript, gordon.ResourceLimits{}, nil)
+	if err != nil {
+		return err
+	}
+
+	return gordon.WaitForExit(payloads, nil, nil)
+}
@@ -0,0 +1,158 @@
+package pool_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/cloudfoundry-incubator/gordon/pool"
+	"github.com/cloudfoundry-incubator/gordon/provision"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pool", func() {
+	var (
+		fakeGordon *fake_gordon.FakeGordon
+		config     Config
+		pool       *Pool
+
+		cleanupExitStatus uint32
+	)
+
+	BeforeEach(func() {
+		fakeGordon = fake_gordon.New()
+
+		config = Config{
+			Size: 2,
+			Spec: provision.Spec{
+				Properties: map[string]string{"owner": "pool"},
+				Limits:     provision.Limits{MemoryInBytes: 1024},
+			},
+		}
+
+		cleanupExitStatus = 0
+	})
+
+	JustBeforeEach(func() {
+		fakeGordon.WhenRunning("", "./cleanup", gordon.ResourceLimits{}, nil, fake_gordon.ExitWith(cleanupExitStatus))
+
+		pool = New(fakeGordon, config)
+	})
+
+	AfterEach(func() {
+		pool.Close()
+	})
+
+	Context("when started", func() {
+		JustBeforeEach(func() {
+			pool.Start()
+			Eventually(func() int { return pool.Stats().Ready }).Should(Equal(2))
+		})
+
+		It("should create containers from the spec", func() {
+			handles := fakeGordon.CreatedHandles()
+			Ω(handles).Should(HaveLen(2))
+
+			for _, handle := range handles {
+				Ω(fakeGordon.CreatedProperties(handle)).Should(Equal(map[string]string{"owner": "pool"}))
+			}
+
+			Ω(fakeGordon.MemoryLimits()).Should(HaveLen(2))
+		})
+
+		It("should hand out ready containers and replenish", func() {
+			container, err := pool.Acquire()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeGordon.CreatedHandles()).Should(ContainElement(container.Handle()))
+
+			Eventually(func() int { return pool.Stats().Ready }).Should(Equal(2))
+
+			stats := pool.Stats()
+			Ω(stats.Hits).Should(Equal(uint64(1)))
+			Ω(stats.Misses).Should(Equal(uint64(0)))
+			Ω(stats.Created).Should(Equal(uint64(3)))
+		})
+
+		It("should destroy the ready containers when closed", func() {
+			Ω(pool.Close()).Should(Succeed())
+
+			Ω(fakeGordon.DestroyedHandles()).Should(ConsistOf(fakeGordon.CreatedHandles()))
+			Ω(pool.Stats().Ready).Should(Equal(0))
+
+			_, err := pool.Acquire()
+			Ω(err).Should(Equal(ErrPoolClosed))
+		})
+	})
+
+	Context("when the pool is empty", func() {
+		It("should create a container and count a miss", func() {
+			container, err := pool.Acquire()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeGordon.CreatedHandles()).Should(Equal([]string{container.Handle()}))
+
+			Ω(pool.Stats().Misses).Should(Equal(uint64(1)))
+		})
+
+		It("should return errors from creating", func() {
+			fakeGordon.CreateError = errors.New("oh no")
+
+			_, err := pool.Acquire()
+			Ω(err).Should(HaveOccurred())
+			Ω(pool.Stats().CreateErrors).Should(Equal(uint64(1)))
+		})
+	})
+
+	Describe("releasing", func() {
+		var container *gordon.Container
+
+		JustBeforeEach(func() {
+			var err error
+			container, err = pool.Acquire()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should destroy the container", func() {
+			Ω(pool.Release(container)).Should(Succeed())
+
+			Ω(fakeGordon.DestroyedHandles()).Should(Equal([]string{container.Handle()}))
+			Ω(pool.Stats().Destroyed).Should(Equal(uint64(1)))
+		})
+
+		Context("with a cleanup script", func() {
+			BeforeEach(func() {
+				config.CleanupScript = "./cleanup"
+			})
+
+			It("should recycle the container", func() {
+				Ω(pool.Release(container)).Should(Succeed())
+
+				Ω(fakeGordon.ScriptsThatRan()).Should(Equal([]*fake_gordon.RunningScript{
+					{Handle: container.Handle(), Script: "./cleanup"},
+				}))
+				Ω(fakeGordon.DestroyedHandles()).Should(BeEmpty())
+
+				stats := pool.Stats()
+				Ω(stats.Recycled).Should(Equal(uint64(1)))
+				Ω(stats.Ready).Should(Equal(1))
+
+				recycled, err := pool.Acquire()
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(recycled.Handle()).Should(Equal(container.Handle()))
+			})
+
+			Context("when the cleanup script fails", func() {
+				BeforeEach(func() {
+					cleanupExitStatus = 1
+				})
+
+				It("should destroy the container", func() {
+					Ω(pool.Release(container)).Should(Succeed())
+
+					Ω(fakeGordon.DestroyedHandles()).Should(Equal([]string{container.Handle()}))
+					Ω(pool.Stats().Recycled).Should(Equal(uint64(0)))
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,38 @@
+package gordon
+
+import (
+	"sync"
+
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+// ProcessStream is the output of a process started with RunStream or
+// followed with AttachStream. Payloads is closed once the process exits, the
+// connection fails, or the stream is detached.
+type ProcessStream struct {
+	ProcessID uint32
+	Payloads  <-chan *warden.ProcessPayload
+
+	detach     func()
+	detachOnce sync.Once
+}
+
+func NewProcessStream(processID uint32, payloads <-chan *warden.ProcessPayload, detach func()) *ProcessStream {
+	return &ProcessStream{
+		ProcessID: processID,
+		Payloads:  payloads,
+		detach:    detach,
+	}
+}
+
+// Detach stops following the process, which keeps running in the container.
+// It returns once the goroutines backing the stream have exited; any
+// payloads not yet read are discarded, and the stream's connection is closed
+// rather than returned to the pool.
+func (s *ProcessStream) Detach() {
+	s.detachOnce.Do(func() {
+		if s.detach != nil {
+			s.detach()
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package provision
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+	"github.com/cloudfoundry-incubator/gordon"
+)
+
+// StepError is returned when a step fails; by then the container has been
+// destroyed, unless RollbackErr says otherwise.
+type StepError struct {
+	Step   string
+	Handle string
+	Err    error
+
+	RollbackErr error
+}
+
+func (e *StepError) Error() string {
+	msg := fmt.Sprintf("%s failed: %s", e.Step, e.Err)
+
+	if e.RollbackErr != nil {
+		msg += fmt.Sprintf(" (and destroying container %s failed: %s)", e.Handle, e.RollbackErr)
+	}
+
+	return msg
+}
+
+type Result struct {
+	Handle string
+	NetIn  []*warden.NetInResponse
+}
+
+type Provisioner struct {
+	client gordon.Client
+
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// New returns a Provisioner that copies bootstrap script output to stdout
+// and stderr, either of which may be nil to discard it.
+func New(client gordon.Client, stdout, stderr io.Writer) *Provisioner {
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+
+	return &Provisioner{
+		client: client,
+		stdout: stdout,
+		stderr: stderr,
+	}
+}
+
+// Apply creates a container and applies the spec to it. If any step fails,
+// the container is destroyed.
+func (p *Provisioner) Apply(spec Spec) (*Result, error) {
+	container, err := gordon.CreateContainer(p.client, spec.Properties)
+	if err != nil {
+		return nil, &StepError{Step: "create", Err: err}
+	}
+
+	result := &Result{Handle: container.Handle()}
+
+	step, err := p.setUp(container, spec, result)
+	if err != nil {
+		_, rollbackErr := container.Destroy()
+
+		return nil, &StepError{
+			Step:        step,
+			Handle:      container.Handle(),
+			Err:         err,
+			RollbackErr: rollbackErr,
+		}
+	}
+
+	return result, nil
+}
+
+func (p *Provisioner) setUp(container *gordon.Container, spec Spec, result *Result) (string, error) {
+	limits := spec.Limits
+
+	if limits.MemoryInBytes > 0 {
+		_, err := container.LimitMemory(limits.MemoryInBytes)
+		if err != nil {
+			return "limit memory", err
+		}
+	}
+
+	if limits.CPUShares > 0 {
+		_, err := container.LimitCPU(limits.CPUShares)
+		if err != nil {
+			return "limit cpu", err
+		}
+	}
+
+	if limits.DiskInBytes > 0 || limits.DiskInodes > 0 {
+		_, err := container.LimitDisk(gordon.DiskLimits{
+			ByteLimit:  limits.DiskInBytes,
+			InodeLimit: limits.DiskInodes,
+		})
+		if err != nil {
+			return "limit disk", err
+		}
+	}
+
+	for _, mapping := range spec.NetIn {
+		res, err := container.NetInWithPorts(mapping.HostPort, mapping.ContainerPort)
+		if err != nil {
+			return "net in", err
+		}
+
+		result.NetIn = append(result.NetIn, res)
+	}
+
+	for _, copyIn := range spec.CopyIn {
+		_, err := container.CopyIn(copyIn.Src, copyIn.Dst)
+		if err != nil {
+			return fmt.Sprintf("copy in %s", copyIn.Src), err
+		}
+	}
+
+	for i, script := range spec.Bootstrap {
+		err := p.run(container, script)
+		if err != nil {
+			return fmt.Sprintf("bootstrap script %d", i+1), err
+		}
+	}
+
+	return "", nil
+}
+
+func (p *Provisioner) run(container *gordon.Container, script Script) error {
+	_, payloads, err := container.Run(
+		script.Script,
+		gordon.ResourceLimits{FileDescriptors: script.FileDescriptors},
+		environment(script.Env),
+	)
+	if err != nil {
+		return err
+	}
+
+	return gordon.WaitForExit(payloads, bestEffort{p.stdout}, bestEffort{p.stderr})
+}
+
+// script output is copied on a best-effort basis; a failing writer doesn't
+// fail the step
+type bestEffort struct {
+	io.Writer
+}
+
+func (w bestEffort) Write(data []byte) (int, error) {
+	w.Writer.Write(data)
+	return len(data), nil
+}
+
+// sorted so that scripts see a deterministic environment
+func environment(env map[string]string) []gordon.EnvironmentVariable {
+	keys := []string{}
+	for key := range env {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	vars := []gordon.EnvironmentVariable{}
+	for _, key := range keys {
+		vars = append(vars, gordon.EnvironmentVariable{Key: key, Value: env[key]})
+	}
+
+	return vars
+}
@@ -0,0 +1,291 @@
+package provision_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/cloudfoundry-incubator/gordon/provision"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Provisioning", func() {
+	Describe("Parsing specs", func() {
+		expectedSpec := Spec{
+			Properties: map[string]string{"owner": "executor"},
+			Limits: Limits{
+				MemoryInBytes: 1024,
+				CPUShares:     10,
+				DiskInBytes:   2048,
+			},
+			NetIn: []PortMapping{
+				{HostPort: 2222, ContainerPort: 22},
+				{ContainerPort: 8080},
+			},
+			CopyIn: []CopyIn{
+				{Src: "/tmp/app", Dst: "/app"},
+			},
+			Bootstrap: []Script{
+				{Script: "./setup", Env: map[string]string{"PORT": "8080"}, FileDescriptors: 32},
+			},
+		}
+
+		yamlSpec := `---
+properties:
+  owner: executor
+limits:
+  memory_in_bytes: 1024
+  cpu_shares: 10
+  disk_in_bytes: 2048
+net_in:
+- host_port: 2222
+  container_port: 22
+- container_port: 8080
+copy_in:
+- src: /tmp/app
+  dst: /app
+bootstrap:
+- script: ./setup
+  env:
+    PORT: "8080"
+  file_descriptors: 32
+`
+
+		jsonSpec := `{
+  "properties": {"owner": "executor"},
+  "limits": {"memory_in_bytes": 1024, "cpu_shares": 10, "disk_in_bytes": 2048},
+  "net_in": [{"host_port": 2222, "container_port": 22}, {"container_port": 8080}],
+  "copy_in": [{"src": "/tmp/app", "dst": "/app"}],
+  "bootstrap": [{"script": "./setup", "env": {"PORT": "8080"}, "file_descriptors": 32}]
+}`
+
+		It("should parse YAML", func() {
+			spec, err := ParseYAML([]byte(yamlSpec))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(spec).Should(Equal(expectedSpec))
+		})
+
+		It("should parse JSON", func() {
+			spec, err := ParseJSON([]byte(jsonSpec))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(spec).Should(Equal(expectedSpec))
+		})
+
+		Describe("LoadSpec", func() {
+			var dir string
+
+			BeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "provision-spec")
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(dir)
+			})
+
+			It("should pick the format from the extension", func() {
+				yamlPath := filepath.Join(dir, "spec.yml")
+				jsonPath := filepath.Join(dir, "spec.json")
+
+				Ω(ioutil.WriteFile(yamlPath, []byte(yamlSpec), 0644)).Should(Succeed())
+				Ω(ioutil.WriteFile(jsonPath, []byte(jsonSpec), 0644)).Should(Succeed())
+
+				spec, err := LoadSpec(yamlPath)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(spec).Should(Equal(expectedSpec))
+
+				spec, err = LoadSpec(jsonPath)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(spec).Should(Equal(expectedSpec))
+			})
+
+			It("should fail when the file is missing", func() {
+				_, err := LoadSpec(filepath.Join(dir, "nope.yml"))
+				Ω(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Applying specs", func() {
+		var (
+			fakeGordon  *fake_gordon.FakeGordon
+			provisioner *Provisioner
+			stdout      *bytes.Buffer
+			spec        Spec
+
+			migrateExitStatus uint32
+		)
+
+		BeforeEach(func() {
+			fakeGordon = fake_gordon.New()
+			stdout = new(bytes.Buffer)
+			provisioner = New(fakeGordon, stdout, nil)
+
+			spec = Spec{
+				Properties: map[string]string{"owner": "executor"},
+				Limits: Limits{
+					MemoryInBytes: 1024,
+					CPUShares:     10,
+					DiskInBytes:   2048,
+					DiskInodes:    100,
+				},
+				NetIn: []PortMapping{
+					{HostPort: 2222, ContainerPort: 22},
+					{},
+				},
+				CopyIn: []CopyIn{
+					{Src: "/tmp/app", Dst: "/app"},
+				},
+				Bootstrap: []Script{
+					{Script: "./setup", Env: map[string]string{"PORT": "8080", "HOME": "/app"}, FileDescriptors: 32},
+					{Script: "./migrate"},
+				},
+			}
+
+			migrateExitStatus = 0
+		})
+
+		JustBeforeEach(func() {
+			fakeGordon.WhenRunning("", "./setup", gordon.ResourceLimits{}, nil, fake_gordon.ExitWith(0, "setting up\n"))
+			fakeGordon.WhenRunning("", "./migrate", gordon.ResourceLimits{}, nil, fake_gordon.ExitWith(migrateExitStatus))
+		})
+
+		It("should create the container and apply every step", func() {
+			result, err := provisioner.Apply(spec)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			handle := result.Handle
+
+			Ω(fakeGordon.CreatedHandles()).Should(Equal([]string{handle}))
+			Ω(fakeGordon.CreatedProperties(handle)).Should(Equal(map[string]string{"owner": "executor"}))
+
+			Ω(fakeGordon.MemoryLimits()).Should(Equal([]fake_gordon.Limit{{Handle: handle, Limit: 1024}}))
+			Ω(fakeGordon.CPULimits()).Should(Equal([]fake_gordon.Limit{{Handle: handle, Limit: 10}}))
+			Ω(fakeGordon.DiskLimits()).Should(Equal([]fake_gordon.DiskLimit{
+				{Handle: handle, Limits: gordon.DiskLimits{ByteLimit: 2048, InodeLimit: 100}},
+			}))
+
+			Ω(fakeGordon.NetIns()).Should(Equal([]fake_gordon.NetIn{
+				{Handle: handle, HostPort: 2222, ContainerPort: 22},
+				{Handle: handle},
+			}))
+			Ω(result.NetIn).Should(HaveLen(2))
+			Ω(result.NetIn[0].GetHostPort()).Should(BeNumerically("==", 2222))
+			Ω(result.NetIn[0].GetContainerPort()).Should(BeNumerically("==", 22))
+			Ω(result.NetIn[1].GetHostPort()).Should(Equal(result.NetIn[1].GetContainerPort()))
+
+			Ω(fakeGordon.ThingsCopiedIn()).Should(Equal([]*fake_gordon.CopiedIn{
+				{Handle: handle, Src: "/tmp/app", Dst: "/app"},
+			}))
+
+			Ω(fakeGordon.ScriptsThatRan()).Should(Equal([]*fake_gordon.RunningScript{
+				{
+					Handle:         handle,
+					Script:         "./setup",
+					ResourceLimits: gordon.ResourceLimits{FileDescriptors: 32},
+					EnvironmentVariables: []gordon.EnvironmentVariable{
+						{Key: "HOME", Value: "/app"},
+						{Key: "PORT", Value: "8080"},
+					},
+				},
+				{
+					Handle:               handle,
+					Script:               "./migrate",
+					EnvironmentVariables: []gordon.EnvironmentVariable{},
+				},
+			}))
+
+			Ω(stdout.String()).Should(Equal("setting up\n"))
+
+			Ω(fakeGordon.DestroyedHandles()).Should(BeEmpty())
+		})
+
+		Context("when creating fails", func() {
+			BeforeEach(func() {
+				fakeGordon.CreateError = errors.New("oh no")
+			})
+
+			It("should return the error without destroying anything", func() {
+				_, err := provisioner.Apply(spec)
+				Ω(err).Should(Equal(&StepError{Step: "create", Err: errors.New("oh no")}))
+
+				Ω(fakeGordon.DestroyedHandles()).Should(BeEmpty())
+			})
+		})
+
+		Context("when a limit fails", func() {
+			BeforeEach(func() {
+				fakeGordon.SetLimitCPUError(errors.New("oh no"))
+			})
+
+			It("should destroy the container and skip the remaining steps", func() {
+				_, err := provisioner.Apply(spec)
+				Ω(err).Should(HaveOccurred())
+
+				stepErr := err.(*StepError)
+				Ω(stepErr.Step).Should(Equal("limit cpu"))
+				Ω(stepErr.Err).Should(Equal(errors.New("oh no")))
+				Ω(stepErr.RollbackErr).ShouldNot(HaveOccurred())
+
+				Ω(fakeGordon.DestroyedHandles()).Should(Equal(fakeGordon.CreatedHandles()))
+				Ω(fakeGordon.DiskLimits()).Should(BeEmpty())
+				Ω(fakeGordon.ScriptsThatRan()).Should(BeEmpty())
+			})
+		})
+
+		Context("when a bootstrap script exits non-zero", func() {
+			BeforeEach(func() {
+				migrateExitStatus = 3
+			})
+
+			It("should destroy the container", func() {
+				_, err := provisioner.Apply(spec)
+				Ω(err).Should(HaveOccurred())
+
+				stepErr := err.(*StepError)
+				Ω(stepErr.Step).Should(Equal("bootstrap script 2"))
+				Ω(stepErr.Err).Should(Equal(&gordon.ExitStatusError{ExitStatus: 3}))
+
+				Ω(fakeGordon.DestroyedHandles()).Should(Equal(fakeGordon.CreatedHandles()))
+			})
+		})
+
+		Context("when the output can't be written", func() {
+			It("should still apply the spec", func() {
+				provisioner = New(fakeGordon, failingWriter{}, failingWriter{})
+
+				_, err := provisioner.Apply(spec)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		Context("when destroying the container also fails", func() {
+			BeforeEach(func() {
+				fakeGordon.SetCopyInErr(errors.New("no such file"))
+				fakeGordon.DestroyError = errors.New("destroy failed")
+			})
+
+			It("should report both errors", func() {
+				_, err := provisioner.Apply(spec)
+				Ω(err).Should(HaveOccurred())
+
+				stepErr := err.(*StepError)
+				Ω(stepErr.Step).Should(Equal("copy in /tmp/app"))
+				Ω(stepErr.RollbackErr).Should(Equal(errors.New("destroy failed")))
+				Ω(err.Error()).Should(ContainSubstring("destroy failed"))
+			})
+		})
+	})
+})
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
@@ -0,0 +1,77 @@
+package provision
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+)
+
+// Spec describes a container and the steps that set it up, in the order
+// they are applied.
+type Spec struct {
+	Properties map[string]string `json:"properties" yaml:"properties"`
+
+	Limits Limits `json:"limits" yaml:"limits"`
+
+	NetIn []PortMapping `json:"net_in" yaml:"net_in"`
+
+	CopyIn []CopyIn `json:"copy_in" yaml:"copy_in"`
+
+	Bootstrap []Script `json:"bootstrap" yaml:"bootstrap"`
+}
+
+// Limits left as zero are not applied.
+type Limits struct {
+	MemoryInBytes uint64 `json:"memory_in_bytes" yaml:"memory_in_bytes"`
+	CPUShares     uint64 `json:"cpu_shares" yaml:"cpu_shares"`
+	DiskInBytes   uint64 `json:"disk_in_bytes" yaml:"disk_in_bytes"`
+	DiskInodes    uint64 `json:"disk_inodes" yaml:"disk_inodes"`
+}
+
+// PortMapping maps a host port into the container. Warden picks the host
+// port if it's left as zero, and maps it to the same port inside the
+// container if ContainerPort is.
+type PortMapping struct {
+	HostPort      uint32 `json:"host_port" yaml:"host_port"`
+	ContainerPort uint32 `json:"container_port" yaml:"container_port"`
+}
+
+type CopyIn struct {
+	Src string `json:"src" yaml:"src"`
+	Dst string `json:"dst" yaml:"dst"`
+}
+
+type Script struct {
+	Script          string            `json:"script" yaml:"script"`
+	Env             map[string]string `json:"env" yaml:"env"`
+	FileDescriptors uint64            `json:"file_descriptors" yaml:"file_descriptors"`
+}
+
+// LoadSpec reads a spec from a file; files ending in .json are parsed as
+// JSON, anything else as YAML.
+func LoadSpec(path string) (Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Spec{}, err
+	}
+
+	if filepath.Ext(path) == ".json" {
+		return ParseJSON(data)
+	}
+
+	return ParseYAML(data)
+}
+
+func ParseJSON(data []byte) (Spec, error) {
+	var spec Spec
+	err := json.Unmarshal(data, &spec)
+	return spec, err
+}
+
+func ParseYAML(data []byte) (Spec, error) {
+	var spec Spec
+	err := candiedyaml.Unmarshal(data, &spec)
+	return spec, err
+}
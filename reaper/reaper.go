@@ -0,0 +1,157 @@
+package reaper
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon"
+)
+
+// Heartbeats reports when an owner was last known to be alive.
+type Heartbeats interface {
+	LastHeartbeat(owner string) (time.Time, bool)
+}
+
+type Config struct {
+	// OwnerProperties selects the containers to consider.
+	OwnerProperties map[string]string
+
+	// LeaseProperty names a property holding an RFC 3339 time after which
+	// the container may be reaped.
+	LeaseProperty string
+
+	// OwnerProperty names a property identifying the owner, whose heartbeat
+	// is looked up in Heartbeats. An owner that has never sent a heartbeat,
+	// or hasn't within HeartbeatTimeout, is considered gone.
+	OwnerProperty    string
+	Heartbeats       Heartbeats
+	HeartbeatTimeout time.Duration
+
+	// DryRun reports what would be reaped without destroying anything.
+	DryRun bool
+
+	// DestroyInterval is the minimum time between two destroys.
+	DestroyInterval time.Duration
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+type Reaped struct {
+	Handle string
+	Reason string
+}
+
+type Report struct {
+	DryRun bool
+	Reaped []Reaped
+
+	// Errors maps handles to whatever went wrong inspecting or destroying
+	// them; those containers are left alone.
+	Errors map[string]error
+}
+
+type Reaper struct {
+	client gordon.Client
+	config Config
+}
+
+func New(client gordon.Client, config Config) *Reaper {
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	return &Reaper{
+		client: client,
+		config: config,
+	}
+}
+
+// Reap destroys every container matching OwnerProperties whose lease has
+// expired or whose owner has stopped sending heartbeats. Only failing to
+// list containers is returned as an error; everything else ends up in the
+// report.
+func (r *Reaper) Reap() (*Report, error) {
+	res, err := r.client.List(r.config.OwnerProperties)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		DryRun: r.config.DryRun,
+		Reaped: []Reaped{},
+		Errors: map[string]error{},
+	}
+
+	var lastDestroy time.Time
+
+	for _, handle := range res.GetHandles() {
+		info, err := r.client.GetInfo(handle)
+		if err != nil {
+			report.Errors[handle] = err
+			continue
+		}
+
+		reason, err := r.reason(info.Properties)
+		if err != nil {
+			report.Errors[handle] = err
+			continue
+		}
+
+		if reason == "" {
+			continue
+		}
+
+		if !r.config.DryRun {
+			if !lastDestroy.IsZero() {
+				time.Sleep(r.config.DestroyInterval - time.Since(lastDestroy))
+			}
+
+			lastDestroy = time.Now()
+
+			_, err := r.client.Destroy(handle)
+			if err != nil {
+				report.Errors[handle] = err
+				continue
+			}
+		}
+
+		report.Reaped = append(report.Reaped, Reaped{Handle: handle, Reason: reason})
+	}
+
+	return report, nil
+}
+
+func (r *Reaper) reason(properties map[string]string) (string, error) {
+	now := r.config.Now()
+
+	if r.config.LeaseProperty != "" {
+		lease, found := properties[r.config.LeaseProperty]
+		if found {
+			expiry, err := time.Parse(time.RFC3339, lease)
+			if err != nil {
+				return "", fmt.Errorf("invalid lease %q: %s", lease, err)
+			}
+
+			if now.After(expiry) {
+				return fmt.Sprintf("lease expired at %s", lease), nil
+			}
+		}
+	}
+
+	if r.config.OwnerProperty != "" && r.config.Heartbeats != nil {
+		owner, found := properties[r.config.OwnerProperty]
+		if found {
+			lastHeartbeat, ok := r.config.Heartbeats.LastHeartbeat(owner)
+			if !ok {
+				return fmt.Sprintf("owner %s has no heartbeat", owner), nil
+			}
+
+			if now.Sub(lastHeartbeat) > r.config.HeartbeatTimeout {
+				return fmt.Sprintf("owner %s last heartbeat at %s", owner, lastHeartbeat.Format(time.RFC3339)), nil
+			}
+		}
+	}
+
+	return "", nil
+}
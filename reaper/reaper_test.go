@@ -0,0 +1,183 @@
+package reaper_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/cloudfoundry-incubator/gordon/reaper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+type fakeHeartbeats map[string]time.Time
+
+func (h fakeHeartbeats) LastHeartbeat(owner string) (time.Time, bool) {
+	heartbeat, found := h[owner]
+	return heartbeat, found
+}
+
+var _ = Describe("Reaper", func() {
+	var (
+		fakeGordon *fake_gordon.FakeGordon
+		config     Config
+		containers map[string]map[string]string
+		now        time.Time
+	)
+
+	BeforeEach(func() {
+		fakeGordon = fake_gordon.New()
+
+		now = time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		containers = map[string]map[string]string{
+			"expired": {"lease": "2014-03-01T11:00:00Z"},
+			"leased":  {"lease": "2014-03-01T13:00:00Z"},
+			"alive":   {"owner": "executor-1"},
+			"stale":   {"owner": "executor-2"},
+			"unknown": {"owner": "executor-3"},
+		}
+
+		config = Config{
+			OwnerProperties: map[string]string{"app": "executor"},
+			LeaseProperty:   "lease",
+			OwnerProperty:   "owner",
+			Heartbeats: fakeHeartbeats{
+				"executor-1": now.Add(-time.Second),
+				"executor-2": now.Add(-time.Hour),
+			},
+			HeartbeatTimeout: time.Minute,
+			Now:              func() time.Time { return now },
+		}
+
+		fakeGordon.WhenListing(func(filter map[string]string) (*warden.ListResponse, error) {
+			Ω(filter).Should(Equal(map[string]string{"app": "executor"}))
+			return &warden.ListResponse{
+				Handles: []string{"expired", "leased", "alive", "stale", "unknown"},
+			}, nil
+		})
+
+		fakeGordon.WhenGettingInfo(func(handle string) (*warden.InfoResponse, error) {
+			properties, found := containers[handle]
+			if !found {
+				return nil, errors.New("unknown handle")
+			}
+
+			info := &warden.InfoResponse{}
+			for key, val := range properties {
+				info.Properties = append(info.Properties, &warden.Property{
+					Key:   proto.String(key),
+					Value: proto.String(val),
+				})
+			}
+
+			return info, nil
+		})
+	})
+
+	It("should destroy containers with expired leases or stale owners", func() {
+		report, err := New(fakeGordon, config).Reap()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(fakeGordon.DestroyedHandles()).Should(Equal([]string{"expired", "stale", "unknown"}))
+
+		Ω(report.DryRun).Should(BeFalse())
+		Ω(report.Errors).Should(BeEmpty())
+		Ω(report.Reaped).Should(Equal([]Reaped{
+			{Handle: "expired", Reason: "lease expired at 2014-03-01T11:00:00Z"},
+			{Handle: "stale", Reason: "owner executor-2 last heartbeat at 2014-03-01T11:00:00Z"},
+			{Handle: "unknown", Reason: "owner executor-3 has no heartbeat"},
+		}))
+	})
+
+	Context("in dry-run mode", func() {
+		BeforeEach(func() {
+			config.DryRun = true
+		})
+
+		It("should report without destroying anything", func() {
+			report, err := New(fakeGordon, config).Reap()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(fakeGordon.DestroyedHandles()).Should(BeEmpty())
+
+			Ω(report.DryRun).Should(BeTrue())
+			Ω(report.Reaped).Should(HaveLen(3))
+		})
+	})
+
+	Context("with a destroy interval", func() {
+		BeforeEach(func() {
+			config.DestroyInterval = 50 * time.Millisecond
+		})
+
+		It("should wait between destroys", func() {
+			started := time.Now()
+
+			_, err := New(fakeGordon, config).Reap()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(fakeGordon.DestroyedHandles()).Should(HaveLen(3))
+			Ω(time.Since(started)).Should(BeNumerically(">=", 100*time.Millisecond))
+		})
+	})
+
+	Context("when listing fails", func() {
+		BeforeEach(func() {
+			fakeGordon.WhenListing(func(map[string]string) (*warden.ListResponse, error) {
+				return nil, errors.New("oh no")
+			})
+		})
+
+		It("should return the error", func() {
+			_, err := New(fakeGordon, config).Reap()
+			Ω(err).Should(Equal(errors.New("oh no")))
+		})
+	})
+
+	Context("when a container disappears before it's inspected", func() {
+		BeforeEach(func() {
+			delete(containers, "expired")
+		})
+
+		It("should report the error and carry on", func() {
+			report, err := New(fakeGordon, config).Reap()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(report.Errors).Should(Equal(map[string]error{"expired": errors.New("unknown handle")}))
+			Ω(fakeGordon.DestroyedHandles()).Should(Equal([]string{"stale", "unknown"}))
+		})
+	})
+
+	Context("when a lease can't be parsed", func() {
+		BeforeEach(func() {
+			containers["leased"]["lease"] = "tomorrow"
+		})
+
+		It("should leave the container alone", func() {
+			report, err := New(fakeGordon, config).Reap()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(report.Errors).Should(HaveKey("leased"))
+			Ω(fakeGordon.DestroyedHandles()).ShouldNot(ContainElement("leased"))
+		})
+	})
+
+	Context("when destroying fails", func() {
+		BeforeEach(func() {
+			fakeGordon.DestroyError = errors.New("destroy failed")
+		})
+
+		It("should not report the container as reaped", func() {
+			report, err := New(fakeGordon, config).Reap()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(report.Reaped).Should(BeEmpty())
+			Ω(report.Errors).Should(HaveLen(3))
+			Ω(report.Errors["expired"]).Should(Equal(errors.New("destroy failed")))
+		})
+	})
+})
@@ -0,0 +1,46 @@
+package gordon
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUserRequiresPrivileged = errors.New("running as another user requires a privileged process")
+
+// RunSpec describes a process in full. Warden has no notion of a working
+// directory or user, so Dir and User are implemented by wrapping the script;
+// switching user needs root, hence Privileged.
+type RunSpec struct {
+	Script               string
+	EnvironmentVariables []EnvironmentVariable
+	ResourceLimits       ResourceLimits
+
+	Privileged bool
+
+	Dir  string
+	User string
+
+	// Signalable tags the process so that Signal can find it later; see
+	// ProcessTokenEnv.
+	Signalable bool
+}
+
+// WrappedScript returns the script as it is sent to warden.
+func (s RunSpec) WrappedScript() (string, error) {
+	script := s.Script
+
+	// on a line of its own, so that it guards every line of the script
+	if s.Dir != "" {
+		script = fmt.Sprintf("cd %s || exit 1\n%s", shellQuote(s.Dir), script)
+	}
+
+	if s.User != "" {
+		if !s.Privileged {
+			return "", ErrUserRequiresPrivileged
+		}
+
+		script = fmt.Sprintf("exec su %s -s /bin/sh -c %s", shellQuote(s.User), shellQuote(script))
+	}
+
+	return script, nil
+}
@@ -0,0 +1,66 @@
+package gordon_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/cloudfoundry-incubator/gordon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RunSpec", func() {
+	It("should leave a plain script alone", func() {
+		script, err := RunSpec{Script: "ls -la"}.WrappedScript()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(script).Should(Equal("ls -la"))
+	})
+
+	It("should change directory first", func() {
+		script, err := RunSpec{Script: "ls", Dir: "/home/it's here"}.WrappedScript()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(script).Should(Equal("cd '/home/it'\"'\"'s here' || exit 1\nls"))
+	})
+
+	Context("with a multi-line script", func() {
+		run := func(dir string) (string, error) {
+			script, err := RunSpec{Script: "pwd\necho second", Dir: dir}.WrappedScript()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			output, err := exec.Command("/bin/sh", "-c", script).Output()
+			return string(output), err
+		}
+
+		It("should run every line in the directory", func() {
+			dir, err := ioutil.TempDir("", "run-spec")
+			Ω(err).ShouldNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			dir, err = filepath.EvalSymlinks(dir)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			output, err := run(dir)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(output).Should(Equal(dir + "\nsecond\n"))
+		})
+
+		It("should run none of it if the directory is missing", func() {
+			output, err := run("/nonexistent")
+			Ω(err).Should(HaveOccurred())
+			Ω(output).Should(BeEmpty())
+		})
+	})
+
+	It("should switch user for privileged processes", func() {
+		script, err := RunSpec{Script: "whoami", User: "vcap", Privileged: true}.WrappedScript()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(script).Should(Equal(`exec su 'vcap' -s /bin/sh -c 'whoami'`))
+	})
+
+	It("should refuse to switch user otherwise", func() {
+		_, err := RunSpec{Script: "whoami", User: "vcap"}.WrappedScript()
+		Ω(err).Should(Equal(ErrUserRequiresPrivileged))
+	})
+})
@@ -0,0 +1,38 @@
+package sampler
+
+type ring struct {
+	buffer []Sample
+	next   int
+	full   bool
+}
+
+func newRing(capacity int) *ring {
+	return &ring{
+		buffer: make([]Sample, capacity),
+	}
+}
+
+func (r *ring) add(sample Sample) {
+	r.buffer[r.next] = sample
+	r.next = (r.next + 1) % len(r.buffer)
+
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *ring) last() (Sample, bool) {
+	if !r.full && r.next == 0 {
+		return Sample{}, false
+	}
+
+	return r.buffer[(r.next+len(r.buffer)-1)%len(r.buffer)], true
+}
+
+func (r *ring) samples() []Sample {
+	if !r.full {
+		return append([]Sample{}, r.buffer[:r.next]...)
+	}
+
+	return append(append([]Sample{}, r.buffer[r.next:]...), r.buffer[:r.next]...)
+}
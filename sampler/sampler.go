@@ -0,0 +1,268 @@
+// Package sampler records containers' resource usage over time.
+package sampler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon"
+)
+
+type Sample struct {
+	Time time.Time `json:"time"`
+
+	MemoryBytes uint64 `json:"memory_bytes"`
+
+	// CPUUsage is the container's cumulative CPU time in nanoseconds, and
+	// CPURate the number of cores it used since the previous sample.
+	CPUUsage uint64  `json:"cpu_usage"`
+	CPURate  float64 `json:"cpu_rate"`
+
+	DiskBytes  uint64 `json:"disk_bytes"`
+	DiskInodes uint64 `json:"disk_inodes"`
+}
+
+// DefaultInterval is used when Config.Interval is zero or less.
+const DefaultInterval = 10 * time.Second
+
+type Config struct {
+	Handles  []string
+	Interval time.Duration
+
+	// Capacity is the number of samples kept per container; older ones are
+	// dropped.
+	Capacity int
+
+	// Concurrency bounds the Info requests in flight at once.
+	Concurrency int
+
+	// OnSample, if set, is called with every sample as it's taken.
+	OnSample func(handle string, sample Sample)
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+type Sampler struct {
+	client gordon.Client
+	config Config
+
+	series  map[string]*ring
+	started bool
+	lock    sync.RWMutex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func New(client gordon.Client, config Config) *Sampler {
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	if config.Capacity < 1 {
+		config.Capacity = 1
+	}
+
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+
+	return &Sampler{
+		client: client,
+		config: config,
+
+		series: map[string]*ring{},
+
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Sample takes one sample of every handle, returning the errors for those
+// that couldn't be inspected.
+func (s *Sampler) Sample() map[string]error {
+	results := s.client.BulkInfo(s.config.Handles, s.config.Concurrency)
+	now := s.config.Now()
+
+	errs := map[string]error{}
+
+	for _, handle := range s.config.Handles {
+		result := results[handle]
+		if result.Err != nil {
+			errs[handle] = result.Err
+			continue
+		}
+
+		info := gordon.NewContainerInfo(result.Info)
+
+		sample := Sample{
+			Time: now,
+
+			MemoryBytes: info.MemoryStat.TotalRss + info.MemoryStat.TotalCache,
+			CPUUsage:    info.CPUStat.Usage,
+			DiskBytes:   info.DiskStat.BytesUsed,
+			DiskInodes:  info.DiskStat.InodesUsed,
+		}
+
+		s.lock.Lock()
+
+		series, found := s.series[handle]
+		if !found {
+			series = newRing(s.config.Capacity)
+			s.series[handle] = series
+		}
+
+		previous, found := series.last()
+		if found {
+			sample.CPURate = cpuRate(previous, sample)
+		}
+
+		series.add(sample)
+
+		s.lock.Unlock()
+
+		if s.config.OnSample != nil {
+			s.config.OnSample(handle, sample)
+		}
+	}
+
+	return errs
+}
+
+// Start samples every Interval until Stop is called. Calling it again has
+// no effect.
+func (s *Sampler) Start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.started {
+		return
+	}
+
+	s.started = true
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			s.Sample()
+
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops a started sampler, waiting for any sample in progress.
+func (s *Sampler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+
+	s.lock.RLock()
+	started := s.started
+	s.lock.RUnlock()
+
+	if started {
+		<-s.done
+	}
+}
+
+// Samples returns the samples kept for the handle, oldest first.
+func (s *Sampler) Samples(handle string) []Sample {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	series, found := s.series[handle]
+	if !found {
+		return []Sample{}
+	}
+
+	return series.samples()
+}
+
+func (s *Sampler) WriteJSON(w io.Writer) error {
+	all := map[string][]Sample{}
+	for _, handle := range s.handles() {
+		all[handle] = s.Samples(handle)
+	}
+
+	return json.NewEncoder(w).Encode(all)
+}
+
+// WriteCSV writes one row per sample, ordered by handle and then time.
+func (s *Sampler) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write([]string{
+		"handle",
+		"time",
+		"memory_bytes",
+		"cpu_usage",
+		"cpu_rate",
+		"disk_bytes",
+		"disk_inodes",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, handle := range s.handles() {
+		for _, sample := range s.Samples(handle) {
+			err := writer.Write([]string{
+				handle,
+				sample.Time.UTC().Format(time.RFC3339Nano),
+				strconv.FormatUint(sample.MemoryBytes, 10),
+				strconv.FormatUint(sample.CPUUsage, 10),
+				strconv.FormatFloat(sample.CPURate, 'f', -1, 64),
+				strconv.FormatUint(sample.DiskBytes, 10),
+				strconv.FormatUint(sample.DiskInodes, 10),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func (s *Sampler) handles() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	handles := []string{}
+	for handle := range s.series {
+		handles = append(handles, handle)
+	}
+
+	sort.Strings(handles)
+
+	return handles
+}
+
+// the counter resets if the container is recreated with the same handle,
+// in which case there's no meaningful rate
+func cpuRate(previous, current Sample) float64 {
+	elapsed := current.Time.Sub(previous.Time)
+	if elapsed <= 0 || current.CPUUsage < previous.CPUUsage {
+		return 0
+	}
+
+	return float64(current.CPUUsage-previous.CPUUsage) / float64(elapsed.Nanoseconds())
+}
@@ -0,0 +1,13 @@
+package sampler_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSampler(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sampler Suite")
+}
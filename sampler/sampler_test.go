@@ -0,0 +1,217 @@
+package sampler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/cloudfoundry-incubator/gordon/sampler"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("Sampler", func() {
+	var (
+		fakeGordon *fake_gordon.FakeGordon
+		config     Config
+		sampler    *Sampler
+
+		now      time.Time
+		cpuUsage uint64
+	)
+
+	BeforeEach(func() {
+		fakeGordon = fake_gordon.New()
+
+		now = time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC)
+		cpuUsage = 0
+
+		fakeGordon.WhenGettingInfo(func(handle string) (*warden.InfoResponse, error) {
+			if handle != "some-handle" {
+				return nil, errors.New("unknown handle")
+			}
+
+			return &warden.InfoResponse{
+				MemoryStat: &warden.InfoResponse_MemoryStat{
+					TotalRss:   proto.Uint64(100),
+					TotalCache: proto.Uint64(20),
+				},
+				CpuStat: &warden.InfoResponse_CpuStat{
+					Usage: proto.Uint64(cpuUsage),
+				},
+				DiskStat: &warden.InfoResponse_DiskStat{
+					BytesUsed:  proto.Uint64(2048),
+					InodesUsed: proto.Uint64(10),
+				},
+			}, nil
+		})
+
+		config = Config{
+			Handles:  []string{"some-handle"},
+			Interval: 10 * time.Millisecond,
+			Capacity: 3,
+			Now:      func() time.Time { return now },
+		}
+	})
+
+	JustBeforeEach(func() {
+		sampler = New(fakeGordon, config)
+	})
+
+	sampleAfter := func(elapsed time.Duration, usage uint64) {
+		now = now.Add(elapsed)
+		cpuUsage = usage
+
+		Ω(sampler.Sample()).Should(BeEmpty())
+	}
+
+	It("should record usage and compute the CPU rate", func() {
+		sampleAfter(0, 1000)
+		sampleAfter(time.Second, 1000+uint64(time.Second/2))
+
+		samples := sampler.Samples("some-handle")
+		Ω(samples).Should(HaveLen(2))
+
+		Ω(samples[0]).Should(Equal(Sample{
+			Time:        now.Add(-time.Second),
+			MemoryBytes: 120,
+			CPUUsage:    1000,
+			DiskBytes:   2048,
+			DiskInodes:  10,
+		}))
+
+		Ω(samples[1].CPURate).Should(Equal(0.5))
+	})
+
+	It("should not compute a rate when the counter resets", func() {
+		sampleAfter(0, 1000)
+		sampleAfter(time.Second, 10)
+
+		Ω(sampler.Samples("some-handle")[1].CPURate).Should(BeZero())
+	})
+
+	It("should only keep the most recent samples", func() {
+		for i := uint64(1); i <= 5; i++ {
+			sampleAfter(time.Second, i)
+		}
+
+		usages := []uint64{}
+		for _, sample := range sampler.Samples("some-handle") {
+			usages = append(usages, sample.CPUUsage)
+		}
+
+		Ω(usages).Should(Equal([]uint64{3, 4, 5}))
+	})
+
+	Context("when a container can't be inspected", func() {
+		BeforeEach(func() {
+			config.Handles = []string{"some-handle", "bogus-handle"}
+		})
+
+		It("should return its error and sample the rest", func() {
+			errs := sampler.Sample()
+			Ω(errs).Should(Equal(map[string]error{"bogus-handle": errors.New("unknown handle")}))
+
+			Ω(sampler.Samples("some-handle")).Should(HaveLen(1))
+			Ω(sampler.Samples("bogus-handle")).Should(BeEmpty())
+		})
+	})
+
+	Context("with a callback", func() {
+		var sampled []string
+
+		BeforeEach(func() {
+			sampled = []string{}
+
+			config.OnSample = func(handle string, sample Sample) {
+				sampled = append(sampled, handle)
+			}
+		})
+
+		It("should call it with every sample", func() {
+			sampleAfter(0, 1)
+			sampleAfter(time.Second, 2)
+
+			Ω(sampled).Should(Equal([]string{"some-handle", "some-handle"}))
+		})
+	})
+
+	Describe("exporting", func() {
+		JustBeforeEach(func() {
+			sampleAfter(0, 0)
+			sampleAfter(time.Second, uint64(time.Second))
+		})
+
+		It("should write CSV", func() {
+			buffer := new(bytes.Buffer)
+			Ω(sampler.WriteCSV(buffer)).Should(Succeed())
+
+			Ω(buffer.String()).Should(Equal(
+				"handle,time,memory_bytes,cpu_usage,cpu_rate,disk_bytes,disk_inodes\n" +
+					"some-handle,2014-03-01T12:00:00Z,120,0,0,2048,10\n" +
+					"some-handle,2014-03-01T12:00:01Z,120,1000000000,1,2048,10\n",
+			))
+		})
+
+		It("should write JSON", func() {
+			buffer := new(bytes.Buffer)
+			Ω(sampler.WriteJSON(buffer)).Should(Succeed())
+
+			var decoded map[string][]Sample
+			Ω(json.Unmarshal(buffer.Bytes(), &decoded)).Should(Succeed())
+
+			Ω(decoded["some-handle"]).Should(HaveLen(2))
+			Ω(decoded["some-handle"][1].CPURate).Should(Equal(1.0))
+		})
+	})
+
+	Describe("sampling in the background", func() {
+		BeforeEach(func() {
+			config.Now = nil
+		})
+
+		It("should sample every interval until stopped", func() {
+			sampler.Start()
+
+			Eventually(func() []Sample { return sampler.Samples("some-handle") }).Should(HaveLen(3))
+
+			sampler.Stop()
+		})
+
+		Context("without an interval", func() {
+			BeforeEach(func() {
+				config.Interval = 0
+			})
+
+			It("should sample at the default interval", func() {
+				sampler.Start()
+
+				Eventually(func() []Sample { return sampler.Samples("some-handle") }).Should(HaveLen(1))
+				Consistently(func() []Sample { return sampler.Samples("some-handle") }, 50*time.Millisecond).Should(HaveLen(1))
+
+				sampler.Stop()
+			})
+		})
+
+		Context("when started twice", func() {
+			BeforeEach(func() {
+				config.Interval = time.Hour
+			})
+
+			It("should only sample in one loop", func() {
+				sampler.Start()
+				sampler.Start()
+
+				Eventually(func() []Sample { return sampler.Samples("some-handle") }).Should(HaveLen(1))
+				Consistently(func() []Sample { return sampler.Samples("some-handle") }, 50*time.Millisecond).Should(HaveLen(1))
+
+				sampler.Stop()
+			})
+		})
+	})
+})
@@ -0,0 +1,37 @@
+package gordon
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrEmptyArgv = errors.New("argv is empty")
+	ErrNULInArgv = errors.New("argv contains a NUL byte, which can't be passed to a process")
+)
+
+// shellQuote quotes s for a POSIX shell, so that it's passed as a single
+// word however it's spelled.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
+// ExecScript returns a script that execs argv as is, with every argument
+// quoted so that nothing in it is interpreted by the shell. Arguments are C
+// strings, so one containing a NUL can't be passed as is and is refused.
+func ExecScript(argv []string) (string, error) {
+	if len(argv) == 0 {
+		return "", ErrEmptyArgv
+	}
+
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if strings.Contains(arg, "\x00") {
+			return "", ErrNULInArgv
+		}
+
+		quoted[i] = shellQuote(arg)
+	}
+
+	return "exec " + strings.Join(quoted, " "), nil
+}
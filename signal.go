@@ -0,0 +1,158 @@
+package gordon
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// ProcessTokenEnv is set in the environment of processes run with
+// RunSpec.Signalable. Warden's process IDs aren't PIDs inside the container,
+// so Signal finds the process, and any children that inherited the variable,
+// by this token instead.
+const ProcessTokenEnv = "GORDON_PROCESS"
+
+var (
+	ErrProcessNotSignalable = errors.New("process was not started as signalable by this client, or its stream has ended")
+	ErrProcessNotRunning    = errors.New("process is not running")
+	ErrInvalidSignal        = errors.New("invalid signal name")
+)
+
+var signalName = regexp.MustCompile(`^[A-Z0-9]+$`)
+
+type processKey struct {
+	handle    string
+	processID uint32
+}
+
+type signalTarget struct {
+	token      string
+	privileged bool
+}
+
+func newSignalTarget(privileged bool) (signalTarget, error) {
+	token := make([]byte, 8)
+
+	_, err := rand.Read(token)
+	if err != nil {
+		return signalTarget{}, err
+	}
+
+	return signalTarget{token: hex.EncodeToString(token), privileged: privileged}, nil
+}
+
+// Signal sends a signal, named as for kill -s (e.g. "TERM" or "SIGKILL"), to
+// a process started with RunSpec.Signalable, for as long as its stream is
+// followed. It runs a kill script in the container, privileged if the process
+// was.
+func (c *client) Signal(handle string, processID uint32, signal string) error {
+	signal = strings.TrimPrefix(strings.ToUpper(signal), "SIG")
+	if !signalName.MatchString(signal) {
+		return ErrInvalidSignal
+	}
+
+	c.lock.Lock()
+	target, found := c.processes[processKey{handle, processID}]
+	c.lock.Unlock()
+
+	if !found {
+		return ErrProcessNotSignalable
+	}
+
+	stream, err := c.RunWithSpec(handle, RunSpec{
+		Script:     signalScript(target.token, signal),
+		Privileged: target.privileged,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = waitForExit(stream.Payloads, ioutil.Discard)
+	if exitErr, ok := err.(*ExitStatusError); ok && exitErr.ExitStatus == 1 {
+		c.forgetProcess(handle, processID)
+		return ErrProcessNotRunning
+	}
+
+	return err
+}
+
+// signalScript signals every process whose environment holds the token, and
+// exits 1 if there were none.
+func signalScript(token, signal string) string {
+	return strings.Join([]string{
+		"found=",
+		"for environ in /proc/[0-9]*/environ; do",
+		fmt.Sprintf("  if tr '\\0' '\\n' < \"$environ\" 2>/dev/null | grep -qxF %s; then", shellQuote(ProcessTokenEnv+"="+token)),
+		"    pid=${environ#/proc/}",
+		fmt.Sprintf("    kill -s %s \"${pid%%/environ}\" 2>/dev/null && found=1", signal),
+		"  fi",
+		"done",
+		"[ -n \"$found\" ] || exit 1",
+	}, "\n")
+}
+
+func (c *client) rememberProcess(handle string, processID uint32, target signalTarget) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.processes[processKey{handle, processID}] = target
+}
+
+func (c *client) forgetProcess(handle string, processID uint32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.processes, processKey{handle, processID})
+}
+
+func (c *client) forgetProcesses(handle string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for key := range c.processes {
+		if key.handle == handle {
+			delete(c.processes, key)
+		}
+	}
+}
+
+// Process is a signalable process started with StartProcess.
+type Process struct {
+	Handle string
+	ID     uint32
+
+	// Stream follows the process's output; once it's detached, the process
+	// can no longer be signalled.
+	Stream *ProcessStream
+
+	client Client
+}
+
+// StartProcess runs spec as a signalable process.
+func StartProcess(client Client, handle string, spec RunSpec) (*Process, error) {
+	spec.Signalable = true
+
+	stream, err := client.RunWithSpec(handle, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Process{
+		Handle: handle,
+		ID:     stream.ProcessID,
+		Stream: stream,
+		client: client,
+	}, nil
+}
+
+func (p *Process) Signal(signal string) error {
+	return p.client.Signal(p.Handle, p.ID, signal)
+}
+
+func (p *Process) Kill() error {
+	return p.Signal("KILL")
+}
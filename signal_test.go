@@ -0,0 +1,272 @@
+package gordon_test
+
+import (
+	"bytes"
+	"regexp"
+
+	. "github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("Signalling a process", func() {
+	var (
+		client            Client
+		stream            *ProcessStream
+		runWriteBuffer    *bytes.Buffer
+		signalWriteBuffer *bytes.Buffer
+		signalResponses   *bytes.Buffer
+	)
+
+	token := regexp.MustCompile(`GORDON_PROCESS.*?([0-9a-f]{16})`)
+
+	processToken := func() string {
+		match := token.FindStringSubmatch(runWriteBuffer.String())
+		Ω(match).Should(HaveLen(2))
+
+		return match[1]
+	}
+
+	killScript := func(token, signal string) string {
+		return "found=\n" +
+			"for environ in /proc/[0-9]*/environ; do\n" +
+			"  if tr '\\0' '\\n' < \"$environ\" 2>/dev/null | grep -qxF 'GORDON_PROCESS=" + token + "'; then\n" +
+			"    pid=${environ#/proc/}\n" +
+			"    kill -s " + signal + " \"${pid%/environ}\" 2>/dev/null && found=1\n" +
+			"  fi\n" +
+			"done\n" +
+			"[ -n \"$found\" ] || exit 1"
+	}
+
+	// starts a process on the first connection, which stays open and busy
+	// streaming it, so the kill script runs on the second
+	start := func(spec RunSpec) {
+		client = NewClient(&ManyConnectionProvider{
+			ConnectionProviders: []ConnectionProvider{
+				NewPipeConnectionProvider(
+					warden.Messages(&warden.ProcessPayload{ProcessId: proto.Uint32(1721)}),
+					runWriteBuffer,
+				),
+				NewFakeConnectionProvider(signalResponses, signalWriteBuffer),
+			},
+		})
+
+		Ω(client.Connect()).Should(Succeed())
+
+		var err error
+		stream, err = client.RunWithSpec("foo", spec)
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+
+	AfterEach(func() {
+		if stream != nil {
+			stream.Detach()
+		}
+	})
+
+	BeforeEach(func() {
+		stream = nil
+		runWriteBuffer = new(bytes.Buffer)
+		signalWriteBuffer = new(bytes.Buffer)
+
+		signalResponses = warden.Messages(
+			&warden.ProcessPayload{ProcessId: proto.Uint32(1722)},
+			&warden.ProcessPayload{ProcessId: proto.Uint32(1722), ExitStatus: proto.Uint32(0)},
+		)
+	})
+
+	It("tags the process with a token in its environment", func() {
+		start(RunSpec{
+			Script:               "sleep 100",
+			EnvironmentVariables: []EnvironmentVariable{{Key: "FOO", Value: "bar"}},
+			Signalable:           true,
+		})
+
+		expectedWriteBufferContents := string(warden.Messages(
+			&warden.RunRequest{
+				Handle:  proto.String("foo"),
+				Script:  proto.String("sleep 100"),
+				Rlimits: &warden.ResourceLimits{},
+				Env: []*warden.EnvironmentVariable{
+					{Key: proto.String("FOO"), Value: proto.String("bar")},
+					{Key: proto.String("GORDON_PROCESS"), Value: proto.String(processToken())},
+				},
+			},
+		).Bytes())
+
+		Ω(runWriteBuffer.String()).Should(Equal(expectedWriteBufferContents))
+	})
+
+	It("runs a kill script for the token", func() {
+		start(RunSpec{Script: "sleep 100", Signalable: true})
+
+		Ω(client.Signal("foo", 1721, "TERM")).Should(Succeed())
+
+		expectedWriteBufferContents := string(warden.Messages(
+			&warden.RunRequest{
+				Handle:  proto.String("foo"),
+				Script:  proto.String(killScript(processToken(), "TERM")),
+				Rlimits: &warden.ResourceLimits{},
+			},
+		).Bytes())
+
+		Ω(signalWriteBuffer.String()).Should(Equal(expectedWriteBufferContents))
+	})
+
+	It("accepts signal names with a SIG prefix, in any case", func() {
+		start(RunSpec{Script: "sleep 100", Signalable: true})
+
+		Ω(client.Signal("foo", 1721, "sigkill")).Should(Succeed())
+		Ω(signalWriteBuffer.String()).Should(ContainSubstring("kill -s KILL"))
+	})
+
+	It("rejects signal names that aren't", func() {
+		start(RunSpec{Script: "sleep 100", Signalable: true})
+
+		Ω(client.Signal("foo", 1721, "TERM; reboot")).Should(Equal(ErrInvalidSignal))
+		Ω(signalWriteBuffer.Len()).Should(BeZero())
+	})
+
+	Context("when the process was privileged", func() {
+		It("runs the kill script privileged", func() {
+			start(RunSpec{Script: "sleep 100", Privileged: true, Signalable: true})
+
+			Ω(client.Signal("foo", 1721, "TERM")).Should(Succeed())
+
+			expectedWriteBufferContents := string(warden.Messages(
+				&warden.RunRequest{
+					Handle:     proto.String("foo"),
+					Script:     proto.String(killScript(processToken(), "TERM")),
+					Privileged: proto.Bool(true),
+					Rlimits:    &warden.ResourceLimits{},
+				},
+			).Bytes())
+
+			Ω(signalWriteBuffer.String()).Should(Equal(expectedWriteBufferContents))
+		})
+	})
+
+	Context("when the process was not started as signalable", func() {
+		It("returns ErrProcessNotSignalable", func() {
+			start(RunSpec{Script: "sleep 100"})
+
+			Ω(client.Signal("foo", 1721, "TERM")).Should(Equal(ErrProcessNotSignalable))
+			Ω(signalWriteBuffer.Len()).Should(BeZero())
+		})
+	})
+
+	Context("when the process is in another container", func() {
+		It("returns ErrProcessNotSignalable", func() {
+			start(RunSpec{Script: "sleep 100", Signalable: true})
+
+			Ω(client.Signal("bar", 1721, "TERM")).Should(Equal(ErrProcessNotSignalable))
+		})
+	})
+
+	Context("when the kill script finds no process", func() {
+		BeforeEach(func() {
+			signalResponses = warden.Messages(
+				&warden.ProcessPayload{ProcessId: proto.Uint32(1722)},
+				&warden.ProcessPayload{ProcessId: proto.Uint32(1722), ExitStatus: proto.Uint32(1)},
+			)
+		})
+
+		It("returns ErrProcessNotRunning and forgets the process", func() {
+			start(RunSpec{Script: "sleep 100", Signalable: true})
+
+			Ω(client.Signal("foo", 1721, "TERM")).Should(Equal(ErrProcessNotRunning))
+			Ω(client.Signal("foo", 1721, "TERM")).Should(Equal(ErrProcessNotSignalable))
+		})
+	})
+
+	Context("when the process has exited", func() {
+		It("forgets it", func() {
+			client = NewClient(NewFakeConnectionProvider(
+				warden.Messages(
+					&warden.ProcessPayload{ProcessId: proto.Uint32(1721)},
+					&warden.ProcessPayload{ProcessId: proto.Uint32(1721), ExitStatus: proto.Uint32(0)},
+				),
+				runWriteBuffer,
+			))
+
+			Ω(client.Connect()).Should(Succeed())
+
+			exited, err := client.RunWithSpec("foo", RunSpec{Script: "true", Signalable: true})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Eventually(exited.Payloads).Should(BeClosed())
+
+			Ω(client.Signal("foo", 1721, "TERM")).Should(Equal(ErrProcessNotSignalable))
+		})
+	})
+
+	Context("when the process's connection is lost", func() {
+		It("forgets it", func() {
+			client = NewClient(NewFakeConnectionProvider(
+				warden.Messages(&warden.ProcessPayload{ProcessId: proto.Uint32(1721)}),
+				runWriteBuffer,
+			))
+
+			Ω(client.Connect()).Should(Succeed())
+
+			lost, err := client.RunWithSpec("foo", RunSpec{Script: "sleep 100", Signalable: true})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Eventually(lost.Payloads).Should(BeClosed())
+
+			Ω(client.Signal("foo", 1721, "TERM")).Should(Equal(ErrProcessNotSignalable))
+		})
+	})
+
+	Context("when the process's stream is detached", func() {
+		It("forgets it", func() {
+			start(RunSpec{Script: "sleep 100", Signalable: true})
+
+			stream.Detach()
+
+			Ω(client.Signal("foo", 1721, "TERM")).Should(Equal(ErrProcessNotSignalable))
+			Ω(signalWriteBuffer.Len()).Should(BeZero())
+		})
+	})
+})
+
+var _ = Describe("Processes", func() {
+	var fakeGordon *fake_gordon.FakeGordon
+
+	BeforeEach(func() {
+		fakeGordon = fake_gordon.New()
+
+		fakeGordon.WhenRunning("some-handle", "sleep 100", ResourceLimits{}, nil, func() (uint32, <-chan *warden.ProcessPayload, error) {
+			return 42, make(chan *warden.ProcessPayload), nil
+		})
+	})
+
+	It("starts the spec as signalable", func() {
+		process, err := StartProcess(fakeGordon, "some-handle", RunSpec{Script: "sleep 100"})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(process.Handle).Should(Equal("some-handle"))
+		Ω(process.ID).Should(Equal(uint32(42)))
+
+		specs := fakeGordon.SpecsThatRan()
+		Ω(specs).Should(HaveLen(1))
+		Ω(specs[0].Spec.Signalable).Should(BeTrue())
+	})
+
+	It("can be killed", func() {
+		process, err := StartProcess(fakeGordon, "some-handle", RunSpec{Script: "sleep 100"})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(process.Kill()).Should(Succeed())
+		Ω(process.Signal("TERM")).Should(Succeed())
+
+		Ω(fakeGordon.ThingsSignalled()).Should(Equal([]*fake_gordon.Signalled{
+			{Handle: "some-handle", ProcessID: 42, Signal: "KILL"},
+			{Handle: "some-handle", ProcessID: 42, Signal: "TERM"},
+		}))
+	})
+})
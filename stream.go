@@ -0,0 +1,218 @@
+package gordon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+	"github.com/cloudfoundry-incubator/gordon/connection"
+)
+
+var ErrNoExitStatus = errors.New("process output ended without an exit status")
+
+// ExitStatusError is returned when a process gordon runs on the caller's
+// behalf exits non-zero.
+type ExitStatusError struct {
+	ExitStatus uint32
+	Stderr     string
+}
+
+func (e *ExitStatusError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("exited with status %d", e.ExitStatus)
+	}
+
+	return fmt.Sprintf("exited with status %d: %s", e.ExitStatus, e.Stderr)
+}
+
+const streamChunkSize = 32 * 1024
+
+// StreamIn extracts the tar archive read from r into dst in the container,
+// by piping it into tar running inside it.
+func (c *client) StreamIn(handle, dst string, r io.Reader) error {
+	conn, err := c.acquireConnection()
+	if err != nil {
+		return err
+	}
+
+	script := fmt.Sprintf("mkdir -p %s && tar xf - -C %s", shellQuote(dst), shellQuote(dst))
+
+	processID, payloads, err := conn.Run(handle, script, &warden.ResourceLimits{}, nil)
+	if err != nil {
+		c.release(conn)
+		return err
+	}
+
+	exited := make(chan error, 1)
+
+	go func() {
+		exited <- waitForExit(payloads, nil)
+	}()
+
+	err = c.sendStdin(conn, processID, r)
+	if err != nil {
+		// the process is still waiting for input
+		c.abandon(conn, payloads)
+		return err
+	}
+
+	err = <-exited
+
+	c.release(conn)
+
+	return err
+}
+
+type stdinChunk struct {
+	data []byte
+	err  error
+}
+
+// sendStdin copies r to the process's stdin. r is read in another goroutine
+// so that the copy can be cancelled by Shutdown even while a read blocks; a
+// read blocked at that point is left to return on its own.
+func (c *client) sendStdin(conn *connection.Connection, processID uint32, r io.Reader) error {
+	chunks := make(chan stdinChunk)
+	next := make(chan struct{})
+	done := make(chan struct{})
+
+	defer close(done)
+
+	go func() {
+		buf := make([]byte, streamChunkSize)
+
+		for {
+			n, err := r.Read(buf)
+
+			select {
+			case chunks <- stdinChunk{buf[:n], err}:
+			case <-done:
+				return
+			}
+
+			if err != nil {
+				return
+			}
+
+			// buf is reused once the chunk has been sent
+			select {
+			case <-next:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case chunk := <-chunks:
+			if len(chunk.data) > 0 {
+				err := conn.SendStdin(processID, chunk.data)
+				if err != nil {
+					return err
+				}
+			}
+
+			if chunk.err == io.EOF {
+				return conn.CloseStdin(processID)
+			}
+
+			if chunk.err != nil {
+				return chunk.err
+			}
+
+			next <- struct{}{}
+
+		case <-c.cancelled:
+			return ErrClientClosed
+		}
+	}
+}
+
+// StreamOut returns a tar archive of src in the container, produced by tar
+// running inside it. Closing the reader before the end stops following the
+// process.
+func (c *client) StreamOut(handle, src string) (io.ReadCloser, error) {
+	src = path.Clean(src)
+
+	script := fmt.Sprintf("tar cf - -C %s %s", shellQuote(path.Dir(src)), shellQuote(path.Base(src)))
+
+	stream, err := c.RunStream(handle, script, ResourceLimits{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStreamOutReader(stream), nil
+}
+
+type streamOutReader struct {
+	*io.PipeReader
+
+	stream *ProcessStream
+}
+
+// reads the process's stdout, failing with an ExitStatusError if it exits
+// non-zero
+func newStreamOutReader(stream *ProcessStream) io.ReadCloser {
+	reader, writer := io.Pipe()
+
+	go func() {
+		writer.CloseWithError(waitForExit(stream.Payloads, writer))
+	}()
+
+	return &streamOutReader{
+		PipeReader: reader,
+		stream:     stream,
+	}
+}
+
+func (r *streamOutReader) Close() error {
+	err := r.PipeReader.Close()
+	r.stream.Detach()
+	return err
+}
+
+// WaitForExit reads payloads until the exit status, copying the process's
+// output to stdout and stderr, either of which may be nil. A non-zero exit
+// status is returned as an ExitStatusError carrying stderr.
+func WaitForExit(payloads <-chan *warden.ProcessPayload, stdout, stderr io.Writer) error {
+	collected := ""
+
+	for payload := range payloads {
+		if payload.ExitStatus != nil {
+			if payload.GetExitStatus() != 0 {
+				return &ExitStatusError{ExitStatus: payload.GetExitStatus(), Stderr: collected}
+			}
+
+			return nil
+		}
+
+		switch payload.GetSource() {
+		case warden.ProcessPayload_stdout:
+			if stdout != nil {
+				_, err := io.WriteString(stdout, payload.GetData())
+				if err != nil {
+					return err
+				}
+			}
+
+		case warden.ProcessPayload_stderr:
+			collected += payload.GetData()
+
+			if stderr != nil {
+				_, err := io.WriteString(stderr, payload.GetData())
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return ErrNoExitStatus
+}
+
+func waitForExit(payloads <-chan *warden.ProcessPayload, stdout io.Writer) error {
+	return WaitForExit(payloads, stdout, nil)
+}
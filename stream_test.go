@@ -0,0 +1,44 @@
+package gordon_test
+
+import (
+	"bytes"
+
+	. "github.com/cloudfoundry-incubator/gordon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("Waiting for a process to exit", func() {
+	stdout := warden.ProcessPayload_stdout
+	stderr := warden.ProcessPayload_stderr
+
+	var payloads chan *warden.ProcessPayload
+
+	BeforeEach(func() {
+		payloads = make(chan *warden.ProcessPayload, 3)
+		payloads <- &warden.ProcessPayload{Source: &stdout, Data: proto.String("out")}
+		payloads <- &warden.ProcessPayload{Source: &stderr, Data: proto.String("err")}
+	})
+
+	It("copies the output and returns a non-zero exit status with stderr", func() {
+		payloads <- &warden.ProcessPayload{ExitStatus: proto.Uint32(2)}
+
+		stdoutBuffer := new(bytes.Buffer)
+		stderrBuffer := new(bytes.Buffer)
+
+		err := WaitForExit(payloads, stdoutBuffer, stderrBuffer)
+		Ω(err).Should(Equal(&ExitStatusError{ExitStatus: 2, Stderr: "err"}))
+
+		Ω(stdoutBuffer.String()).Should(Equal("out"))
+		Ω(stderrBuffer.String()).Should(Equal("err"))
+	})
+
+	It("returns ErrNoExitStatus if the payloads end without one", func() {
+		close(payloads)
+
+		Ω(WaitForExit(payloads, nil, nil)).Should(Equal(ErrNoExitStatus))
+	})
+})
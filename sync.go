@@ -0,0 +1,243 @@
+package gordon
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SyncReport lists the paths, relative to the synced directories, that Sync
+// changed.
+type SyncReport struct {
+	Added     []string
+	Updated   []string
+	Deleted   []string
+	Unchanged int
+}
+
+// Sync makes containerDir match localDir, comparing SHA-1 checksums so that
+// only new and changed files are streamed in. Files only in the container
+// are deleted; directories are left alone.
+func Sync(client Client, handle, localDir, containerDir string) (*SyncReport, error) {
+	local, err := localChecksums(localDir)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := remoteChecksums(client, handle, containerDir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SyncReport{
+		Added:   []string{},
+		Updated: []string{},
+		Deleted: []string{},
+	}
+
+	for path, checksum := range local {
+		remoteChecksum, found := remote[path]
+
+		switch {
+		case !found:
+			report.Added = append(report.Added, path)
+		case remoteChecksum != checksum:
+			report.Updated = append(report.Updated, path)
+		default:
+			report.Unchanged++
+		}
+	}
+
+	for path := range remote {
+		_, found := local[path]
+		if !found {
+			report.Deleted = append(report.Deleted, path)
+		}
+	}
+
+	sort.Strings(report.Added)
+	sort.Strings(report.Updated)
+	sort.Strings(report.Deleted)
+
+	transfer := append(append([]string{}, report.Added...), report.Updated...)
+
+	if len(transfer) > 0 {
+		err := streamFiles(client, handle, localDir, containerDir, transfer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(report.Deleted) > 0 {
+		err := deleteFiles(client, handle, containerDir, report.Deleted)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+func (c *Container) Sync(localDir, containerDir string) (*SyncReport, error) {
+	return Sync(c.client, c.handle, localDir, containerDir)
+}
+
+func localChecksums(dir string) (map[string]string, error) {
+	checksums := map[string]string{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		hash := sha1.New()
+
+		_, err = io.Copy(hash, file)
+		if err != nil {
+			return err
+		}
+
+		checksums[filepath.ToSlash(rel)] = hex.EncodeToString(hash.Sum(nil))
+
+		return nil
+	})
+
+	return checksums, err
+}
+
+// a missing directory has no files; sha1sum -z terminates each line with a
+// NUL instead of escaping names containing a backslash or newline
+func remoteChecksums(client Client, handle, dir string) (map[string]string, error) {
+	script := fmt.Sprintf(
+		"if [ -d %s ]; then cd %s && find . -type f -exec sha1sum -z {} +; fi",
+		shellQuote(dir),
+		shellQuote(dir),
+	)
+
+	_, payloads, err := client.Run(handle, script, ResourceLimits{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	output := new(bytes.Buffer)
+
+	err = waitForExit(payloads, output)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := map[string]string{}
+
+	for output.Len() > 0 {
+		line, err := output.ReadString(0)
+		if err != nil {
+			return nil, fmt.Errorf("unterminated checksum output: %q", line)
+		}
+
+		fields := strings.SplitN(strings.TrimSuffix(line, "\x00"), "  ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("unexpected checksum output: %q", line)
+		}
+
+		checksums[strings.TrimPrefix(fields[1], "./")] = fields[0]
+	}
+
+	return checksums, nil
+}
+
+func streamFiles(client Client, handle, localDir, containerDir string, paths []string) error {
+	reader, writer := io.Pipe()
+
+	go func() {
+		writer.CloseWithError(writeTar(writer, localDir, paths))
+	}()
+
+	err := client.StreamIn(handle, containerDir, reader)
+
+	// unblock the writer if StreamIn gave up early
+	reader.Close()
+
+	return err
+}
+
+func writeTar(w io.Writer, dir string, paths []string) error {
+	archive := tar.NewWriter(w)
+
+	for _, path := range paths {
+		err := addToTar(archive, dir, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func addToTar(archive *tar.Writer, dir, path string) error {
+	file, err := os.Open(filepath.Join(dir, filepath.FromSlash(path)))
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+
+	header.Name = path
+
+	err = archive.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(archive, file)
+
+	return err
+}
+
+func deleteFiles(client Client, handle, dir string, paths []string) error {
+	quoted := []string{}
+	for _, path := range paths {
+		quoted = append(quoted, shellQuote(path))
+	}
+
+	script := fmt.Sprintf("cd %s && rm -f -- %s", shellQuote(dir), strings.Join(quoted, " "))
+
+	_, payloads, err := client.Run(handle, script, ResourceLimits{}, nil)
+	if err != nil {
+		return err
+	}
+
+	return waitForExit(payloads, nil)
+}
@@ -0,0 +1,171 @@
+package gordon_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Syncing a directory", func() {
+	var (
+		fakeGordon *fake_gordon.FakeGordon
+		localDir   string
+	)
+
+	checksumScript := "if [ -d '/app' ]; then cd '/app' && find . -type f -exec sha1sum -z {} +; fi"
+
+	checksum := func(content string) string {
+		sum := sha1.Sum([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+
+	writeFile := func(path, content string) {
+		path = filepath.Join(localDir, path)
+
+		Ω(os.MkdirAll(filepath.Dir(path), 0755)).Should(Succeed())
+		Ω(ioutil.WriteFile(path, []byte(content), 0644)).Should(Succeed())
+	}
+
+	streamedFiles := func() map[string]string {
+		streamed := fakeGordon.ThingsStreamedIn()
+		Ω(streamed).Should(HaveLen(1))
+		Ω(streamed[0].Handle).Should(Equal("some-handle"))
+		Ω(streamed[0].Dst).Should(Equal("/app"))
+
+		files := map[string]string{}
+
+		archive := tar.NewReader(bytes.NewReader(streamed[0].Data))
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+
+			Ω(err).ShouldNot(HaveOccurred())
+
+			content, err := ioutil.ReadAll(archive)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			files[header.Name] = string(content)
+		}
+
+		return files
+	}
+
+	BeforeEach(func() {
+		fakeGordon = fake_gordon.New()
+
+		var err error
+		localDir, err = ioutil.TempDir("", "sync")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		writeFile("same.txt", "same")
+		writeFile("changed.txt", "new content")
+		writeFile("sub/added.txt", "added")
+
+		fakeGordon.WhenRunning("some-handle", "cd '/app' && rm -f -- 'gone.txt'", ResourceLimits{}, nil, fake_gordon.ExitWith(0, ""))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(localDir)
+	})
+
+	It("should only transfer what changed, and delete what was removed", func() {
+		fakeGordon.WhenRunning("some-handle", checksumScript, ResourceLimits{}, nil, fake_gordon.ExitWith(0,
+			checksum("same")+"  ./same.txt\x00"+
+				checksum("old content")+"  ./changed.txt\x00"+
+				checksum("gone")+"  ./gone.txt\x00",
+		))
+
+		report, err := Sync(fakeGordon, "some-handle", localDir, "/app")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(report).Should(Equal(&SyncReport{
+			Added:     []string{"sub/added.txt"},
+			Updated:   []string{"changed.txt"},
+			Deleted:   []string{"gone.txt"},
+			Unchanged: 1,
+		}))
+
+		Ω(streamedFiles()).Should(Equal(map[string]string{
+			"sub/added.txt": "added",
+			"changed.txt":   "new content",
+		}))
+
+		Ω(fakeGordon.ScriptsThatRan()).Should(HaveLen(2))
+		Ω(fakeGordon.ScriptsThatRan()[1].Script).Should(Equal("cd '/app' && rm -f -- 'gone.txt'"))
+	})
+
+	It("should not do anything when nothing changed", func() {
+		fakeGordon.WhenRunning("some-handle", checksumScript, ResourceLimits{}, nil, fake_gordon.ExitWith(0,
+			checksum("same")+"  ./same.txt\x00"+
+				checksum("new content")+"  ./changed.txt\x00"+
+				checksum("added")+"  ./sub/added.txt\x00",
+		))
+
+		report, err := Sync(fakeGordon, "some-handle", localDir, "/app")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(report.Unchanged).Should(Equal(3))
+
+		Ω(fakeGordon.ThingsStreamedIn()).Should(BeEmpty())
+		Ω(fakeGordon.ScriptsThatRan()).Should(HaveLen(1))
+	})
+
+	It("should match names that sha1sum would otherwise escape", func() {
+		writeFile("back\\slash.txt", "back")
+		writeFile("new\nline.txt", "new")
+
+		fakeGordon.WhenRunning("some-handle", checksumScript, ResourceLimits{}, nil, fake_gordon.ExitWith(0,
+			checksum("same")+"  ./same.txt\x00"+
+				checksum("new content")+"  ./changed.txt\x00"+
+				checksum("added")+"  ./sub/added.txt\x00"+
+				checksum("back")+"  ./back\\slash.txt\x00"+
+				checksum("new")+"  ./new\nline.txt\x00",
+		))
+
+		report, err := Sync(fakeGordon, "some-handle", localDir, "/app")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(report.Unchanged).Should(Equal(5))
+
+		Ω(fakeGordon.ThingsStreamedIn()).Should(BeEmpty())
+	})
+
+	It("should fail on output it doesn't understand", func() {
+		fakeGordon.WhenRunning("some-handle", checksumScript, ResourceLimits{}, nil, fake_gordon.ExitWith(0,
+			checksum("same")+"  ./same.txt\n",
+		))
+
+		_, err := Sync(fakeGordon, "some-handle", localDir, "/app")
+		Ω(err).Should(HaveOccurred())
+
+		Ω(fakeGordon.ThingsStreamedIn()).Should(BeEmpty())
+	})
+
+	It("should fail if the checksums can't be listed", func() {
+		fakeGordon.WhenRunning("some-handle", checksumScript, ResourceLimits{}, nil, fake_gordon.ExitWith(1, ""))
+
+		_, err := Sync(fakeGordon, "some-handle", localDir, "/app")
+		Ω(err).Should(Equal(&ExitStatusError{ExitStatus: 1}))
+
+		Ω(fakeGordon.ThingsStreamedIn()).Should(BeEmpty())
+	})
+
+	It("should fail if streaming fails", func() {
+		fakeGordon.WhenRunning("some-handle", checksumScript, ResourceLimits{}, nil, fake_gordon.ExitWith(0, ""))
+		fakeGordon.SetStreamInErr(errors.New("oh no"))
+
+		_, err := Sync(fakeGordon, "some-handle", localDir, "/app")
+		Ω(err).Should(Equal(errors.New("oh no")))
+	})
+})
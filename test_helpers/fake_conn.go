@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"net"
+	"sync"
 	"time"
 )
 
@@ -11,11 +12,13 @@ type FakeConn struct {
 	ReadBuffer  *bytes.Buffer
 	WriteBuffer *bytes.Buffer
 	WriteChan   chan string
-	Closed      bool
+
+	closed     bool
+	closedLock sync.Mutex
 }
 
 func (f *FakeConn) Read(b []byte) (n int, err error) {
-	if f.Closed {
+	if f.IsClosed() {
 		return 0, errors.New("buffer closed")
 	}
 
@@ -23,7 +26,7 @@ func (f *FakeConn) Read(b []byte) (n int, err error) {
 }
 
 func (f *FakeConn) Write(b []byte) (n int, err error) {
-	if f.Closed {
+	if f.IsClosed() {
 		return 0, errors.New("buffer closed")
 	}
 
@@ -35,10 +38,21 @@ func (f *FakeConn) Write(b []byte) (n int, err error) {
 }
 
 func (f *FakeConn) Close() error {
-	f.Closed = true
+	f.closedLock.Lock()
+	defer f.closedLock.Unlock()
+
+	f.closed = true
+
 	return nil
 }
 
+func (f *FakeConn) IsClosed() bool {
+	f.closedLock.Lock()
+	defer f.closedLock.Unlock()
+
+	return f.closed
+}
+
 func (f *FakeConn) SetDeadline(time.Time) error {
 	return nil
 }
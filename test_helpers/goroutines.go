@@ -0,0 +1,54 @@
+package test_helpers
+
+import (
+	"runtime"
+	"strings"
+)
+
+// GoroutinesCreatedBy returns the IDs of live goroutines that were started
+// by a function whose name contains fn, e.g. "(*Connection).Run".
+func GoroutinesCreatedBy(fn string) map[string]bool {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+
+		buf = make([]byte, 2*len(buf))
+	}
+
+	ids := map[string]bool{}
+
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		lines := strings.Split(stack, "\n")
+
+		for _, line := range lines {
+			if strings.HasPrefix(line, "created by ") && strings.Contains(line, fn) {
+				// "goroutine 42 [chan send]:"
+				ids[strings.Fields(lines[0])[1]] = true
+			}
+		}
+	}
+
+	return ids
+}
+
+// NewGoroutines returns a function that lists which goroutines started by fn
+// since NewGoroutines was called are still alive.
+func NewGoroutines(fn string) func() []string {
+	before := GoroutinesCreatedBy(fn)
+
+	return func() []string {
+		alive := []string{}
+
+		for id := range GoroutinesCreatedBy(fn) {
+			if !before[id] {
+				alive = append(alive, id)
+			}
+		}
+
+		return alive
+	}
+}
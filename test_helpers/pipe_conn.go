@@ -0,0 +1,20 @@
+package test_helpers
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+)
+
+// NewPipeConn returns one end of an in-memory connection whose other end
+// discards whatever is written to it and sends replies, then stays silent
+// until the connection is closed.
+func NewPipeConn(replies *bytes.Buffer) net.Conn {
+	clientEnd, serverEnd := net.Pipe()
+
+	go io.Copy(ioutil.Discard, serverEnd)
+	go serverEnd.Write(replies.Bytes())
+
+	return clientEnd
+}
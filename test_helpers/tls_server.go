@@ -0,0 +1,196 @@
+package test_helpers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+type TLSCertificates struct {
+	CACertFile string
+
+	ServerCertFile string
+	ServerKeyFile  string
+
+	ClientCertFile string
+	ClientKeyFile  string
+
+	caCert *x509.Certificate
+}
+
+// GenerateTLSCertificates writes a throwaway CA, plus a server certificate
+// valid for 127.0.0.1 and localhost and a client certificate, both signed by
+// it, into dir.
+func GenerateTLSCertificates(dir string) (*TLSCertificates, error) {
+	certs := &TLSCertificates{
+		CACertFile:     filepath.Join(dir, "ca.crt"),
+		ServerCertFile: filepath.Join(dir, "server.crt"),
+		ServerKeyFile:  filepath.Join(dir, "server.key"),
+		ClientCertFile: filepath.Join(dir, "client.crt"),
+		ClientKeyFile:  filepath.Join(dir, "client.key"),
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	caTemplate := certificateTemplate(1, "gordon test CA")
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	certs.caCert, err = x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writePEM(certs.CACertFile, "CERTIFICATE", caDER)
+	if err != nil {
+		return nil, err
+	}
+
+	serverTemplate := certificateTemplate(2, "gordon test server")
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	serverTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	serverTemplate.DNSNames = []string{"localhost"}
+
+	err = certs.issue(serverTemplate, caKey, certs.ServerCertFile, certs.ServerKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	clientTemplate := certificateTemplate(3, "gordon test client")
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+
+	err = certs.issue(clientTemplate, caKey, certs.ClientCertFile, certs.ClientKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return certs, nil
+}
+
+// ServerConfig returns a config serving the server certificate; if
+// requireClientCert is set, clients must present a certificate signed by
+// the CA.
+func (c *TLSCertificates) ServerConfig(requireClientCert bool) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.ServerCertFile, c.ServerKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if requireClientCert {
+		pool := x509.NewCertPool()
+		pool.AddCert(c.caCert)
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+func (c *TLSCertificates) issue(template *x509.Certificate, caKey *ecdsa.PrivateKey, certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, c.caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	err = writePEM(certFile, "CERTIFICATE", der)
+	if err != nil {
+		return err
+	}
+
+	return writePEM(keyFile, "EC PRIVATE KEY", keyDER)
+}
+
+func certificateTemplate(serial int64, commonName string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+}
+
+func writePEM(path, blockType string, der []byte) error {
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+}
+
+// TLSServer accepts TLS connections, completing the handshake on each and
+// reporting the outcome on Handshakes.
+type TLSServer struct {
+	Listener   net.Listener
+	Handshakes chan error
+}
+
+func NewTLSServer(config *tls.Config) (*TLSServer, error) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &TLSServer{
+		Listener:   listener,
+		Handshakes: make(chan error, 10),
+	}
+
+	go server.serve()
+
+	return server, nil
+}
+
+func (s *TLSServer) Addr() string {
+	return s.Listener.Addr().String()
+}
+
+func (s *TLSServer) Close() error {
+	return s.Listener.Close()
+}
+
+func (s *TLSServer) serve() {
+	for {
+		conn, err := s.Listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			err := conn.(*tls.Conn).Handshake()
+			s.Handshakes <- err
+			if err != nil {
+				conn.Close()
+			}
+		}()
+	}
+}
@@ -0,0 +1,67 @@
+package gordon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+)
+
+var ErrInvalidCABundle = errors.New("no certificates found in CA bundle")
+
+type TLSConfig struct {
+	// PEM bundle used to verify the server; the system roots are used if empty
+	CACertFile string
+
+	// client certificate and key presented to the server for mutual TLS
+	CertFile string
+	KeyFile  string
+
+	// defaults to the host portion of ConnectionInfo.Addr
+	ServerName string
+
+	// e.g. tls.VersionTLS12; defaults to crypto/tls's minimum
+	MinVersion uint16
+}
+
+func (c *TLSConfig) clientConfig(addr string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: c.ServerName,
+		MinVersion: c.MinVersion,
+	}
+
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		config.ServerName = host
+	}
+
+	if c.CACertFile != "" {
+		bundle, err := ioutil.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, ErrInvalidCABundle
+		}
+
+		config.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,276 @@
+package gordon
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+type EventType string
+
+const (
+	ContainerCreated   EventType = "created"
+	ContainerDestroyed EventType = "destroyed"
+	StateChanged       EventType = "state_changed"
+	ContainerEvent     EventType = "event"
+	LimitChanged       EventType = "limit_changed"
+	WatchFailed        EventType = "watch_failed"
+)
+
+// Limits reported by LimitChanged events.
+const (
+	MemoryLimit       = "memory"
+	BandwidthInLimit  = "bandwidth in"
+	BandwidthOutLimit = "bandwidth out"
+)
+
+type Event struct {
+	Type   EventType
+	Handle string
+
+	// set for StateChanged
+	State         string
+	PreviousState string
+
+	// set for ContainerEvent, e.g. "out of memory"
+	Event string
+
+	// set for LimitChanged
+	Limit         string
+	Value         uint64
+	PreviousValue uint64
+
+	// set for WatchFailed, when listing containers fails
+	Err error
+}
+
+// Watcher polls warden and emits an Event for every difference it sees
+// between two polls. The containers present at the first poll are taken as
+// the starting point, and don't generate events themselves.
+type Watcher struct {
+	Events <-chan Event
+
+	client           Client
+	filterProperties map[string]string
+	interval         time.Duration
+
+	events chan Event
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+type snapshot struct {
+	state  string
+	events map[string]int
+	limits map[string]uint64
+}
+
+// DefaultWatchInterval is used when NewWatcher is given an interval of zero
+// or less.
+const DefaultWatchInterval = time.Second
+
+func NewWatcher(client Client, filterProperties map[string]string, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	events := make(chan Event)
+
+	watcher := &Watcher{
+		Events: events,
+
+		client:           client,
+		filterProperties: filterProperties,
+		interval:         interval,
+
+		events: events,
+
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go watcher.watch()
+
+	return watcher
+}
+
+// Stop stops polling and closes Events, discarding any events not yet read.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+
+	<-w.done
+}
+
+func (w *Watcher) watch() {
+	defer close(w.done)
+	defer close(w.events)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var previous map[string]*snapshot
+
+	for {
+		current, err := w.poll(previous)
+		if err != nil {
+			if !w.emit(Event{Type: WatchFailed, Err: err}) {
+				return
+			}
+		} else {
+			if previous != nil {
+				for _, event := range diff(previous, current) {
+					if !w.emit(event) {
+						return
+					}
+				}
+			}
+
+			previous = current
+		}
+
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// a listed container that can't be inspected keeps its previous snapshot,
+// so a failed Info isn't mistaken for the container going away; one not seen
+// before is left out until it can be inspected
+func (w *Watcher) poll(previous map[string]*snapshot) (map[string]*snapshot, error) {
+	res, err := w.client.List(w.filterProperties)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := map[string]*snapshot{}
+
+	for _, handle := range res.GetHandles() {
+		info, err := w.client.Info(handle)
+		if err != nil {
+			if before, found := previous[handle]; found {
+				snapshots[handle] = before
+			}
+
+			continue
+		}
+
+		snapshots[handle] = takeSnapshot(info)
+	}
+
+	return snapshots, nil
+}
+
+func (w *Watcher) emit(event Event) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-w.stop:
+		return false
+	}
+}
+
+func takeSnapshot(info *warden.InfoResponse) *snapshot {
+	events := map[string]int{}
+	for _, event := range info.GetEvents() {
+		events[event]++
+	}
+
+	limits := map[string]uint64{}
+
+	if info.GetMemoryStat() != nil {
+		limits[MemoryLimit] = info.GetMemoryStat().GetHierarchicalMemoryLimit()
+	}
+
+	if info.GetBandwidthStat() != nil {
+		limits[BandwidthInLimit] = info.GetBandwidthStat().GetInRate()
+		limits[BandwidthOutLimit] = info.GetBandwidthStat().GetOutRate()
+	}
+
+	return &snapshot{
+		state:  info.GetState(),
+		events: events,
+		limits: limits,
+	}
+}
+
+func diff(previous, current map[string]*snapshot) []Event {
+	events := []Event{}
+
+	for _, handle := range sortedHandles(current) {
+		now := current[handle]
+
+		before, found := previous[handle]
+		if !found {
+			events = append(events, Event{Type: ContainerCreated, Handle: handle, State: now.state})
+			continue
+		}
+
+		if now.state != before.state {
+			events = append(events, Event{
+				Type:          StateChanged,
+				Handle:        handle,
+				State:         now.state,
+				PreviousState: before.state,
+			})
+		}
+
+		for _, event := range sortedKeys(now.events) {
+			for i := before.events[event]; i < now.events[event]; i++ {
+				events = append(events, Event{Type: ContainerEvent, Handle: handle, Event: event})
+			}
+		}
+
+		for _, limit := range []string{MemoryLimit, BandwidthInLimit, BandwidthOutLimit} {
+			value, found := now.limits[limit]
+			if found && value != before.limits[limit] {
+				events = append(events, Event{
+					Type:          LimitChanged,
+					Handle:        handle,
+					Limit:         limit,
+					Value:         value,
+					PreviousValue: before.limits[limit],
+				})
+			}
+		}
+	}
+
+	for _, handle := range sortedHandles(previous) {
+		_, found := current[handle]
+		if !found {
+			events = append(events, Event{Type: ContainerDestroyed, Handle: handle})
+		}
+	}
+
+	return events
+}
+
+func sortedHandles(snapshots map[string]*snapshot) []string {
+	handles := []string{}
+	for handle := range snapshots {
+		handles = append(handles, handle)
+	}
+
+	sort.Strings(handles)
+
+	return handles
+}
+
+func sortedKeys(counts map[string]int) []string {
+	keys := []string{}
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,202 @@
+package gordon_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/cloudfoundry-incubator/gordon"
+	"github.com/cloudfoundry-incubator/gordon/fake_gordon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gogoprotobuf/proto"
+	warden "github.com/cloudfoundry-incubator/garden/protocol"
+)
+
+var _ = Describe("Watching containers", func() {
+	var (
+		fakeGordon *fake_gordon.FakeGordon
+		watcher    *Watcher
+
+		containers map[string]*warden.InfoResponse
+		listErr    error
+		infoErrs   map[string]error
+		listCalls  int
+		lock       sync.Mutex
+	)
+
+	// once the second List has started, the first poll, whose containers
+	// are the starting point, is complete
+	waitForFirstPoll := func() {
+		Eventually(func() int {
+			lock.Lock()
+			defer lock.Unlock()
+
+			return listCalls
+		}).Should(BeNumerically(">=", 2))
+	}
+
+	update := func(fn func()) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		fn()
+	}
+
+	BeforeEach(func() {
+		fakeGordon = fake_gordon.New()
+
+		containers = map[string]*warden.InfoResponse{
+			"a": {State: proto.String("active")},
+			"b": {State: proto.String("active")},
+		}
+
+		listErr = nil
+		infoErrs = map[string]error{}
+		listCalls = 0
+
+		fakeGordon.WhenListing(func(filter map[string]string) (*warden.ListResponse, error) {
+			Ω(filter).Should(Equal(map[string]string{"owner": "executor"}))
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			listCalls++
+
+			if listErr != nil {
+				return nil, listErr
+			}
+
+			handles := []string{}
+			for handle := range containers {
+				handles = append(handles, handle)
+			}
+
+			return &warden.ListResponse{Handles: handles}, nil
+		})
+
+		fakeGordon.WhenGettingInfo(func(handle string) (*warden.InfoResponse, error) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			if infoErrs[handle] != nil {
+				return nil, infoErrs[handle]
+			}
+
+			info, found := containers[handle]
+			if !found {
+				return nil, errors.New("unknown handle")
+			}
+
+			return info, nil
+		})
+
+		watcher = fakeGordon.Watch(map[string]string{"owner": "executor"}, 10*time.Millisecond)
+	})
+
+	AfterEach(func() {
+		watcher.Stop()
+	})
+
+	It("should not report the containers that were there to begin with", func() {
+		Consistently(watcher.Events, 50*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("should report containers appearing and disappearing", func() {
+		waitForFirstPoll()
+
+		update(func() {
+			containers["c"] = &warden.InfoResponse{State: proto.String("born")}
+			delete(containers, "a")
+		})
+
+		Eventually(watcher.Events).Should(Receive(Equal(Event{Type: ContainerCreated, Handle: "c", State: "born"})))
+		Eventually(watcher.Events).Should(Receive(Equal(Event{Type: ContainerDestroyed, Handle: "a"})))
+	})
+
+	It("should report state changes", func() {
+		waitForFirstPoll()
+
+		update(func() {
+			containers["a"] = &warden.InfoResponse{State: proto.String("stopped")}
+		})
+
+		Eventually(watcher.Events).Should(Receive(Equal(Event{
+			Type:          StateChanged,
+			Handle:        "a",
+			State:         "stopped",
+			PreviousState: "active",
+		})))
+	})
+
+	It("should report new warden events once", func() {
+		waitForFirstPoll()
+
+		update(func() {
+			containers["b"] = &warden.InfoResponse{
+				State:  proto.String("active"),
+				Events: []string{"out of memory"},
+			}
+		})
+
+		Eventually(watcher.Events).Should(Receive(Equal(Event{Type: ContainerEvent, Handle: "b", Event: "out of memory"})))
+		Consistently(watcher.Events, 50*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("should report limit changes", func() {
+		waitForFirstPoll()
+
+		update(func() {
+			containers["a"] = &warden.InfoResponse{
+				State: proto.String("active"),
+				MemoryStat: &warden.InfoResponse_MemoryStat{
+					HierarchicalMemoryLimit: proto.Uint64(1024),
+				},
+			}
+		})
+
+		Eventually(watcher.Events).Should(Receive(Equal(Event{
+			Type:   LimitChanged,
+			Handle: "a",
+			Limit:  MemoryLimit,
+			Value:  1024,
+		})))
+	})
+
+	It("should not report containers that can't be inspected as destroyed", func() {
+		waitForFirstPoll()
+
+		update(func() {
+			infoErrs["a"] = errors.New("timed out")
+		})
+
+		Consistently(watcher.Events, 50*time.Millisecond).ShouldNot(Receive())
+
+		update(func() {
+			delete(infoErrs, "a")
+		})
+
+		Consistently(watcher.Events, 50*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("should report failures to list", func() {
+		update(func() {
+			listErr = errors.New("oh no")
+		})
+
+		Eventually(watcher.Events).Should(Receive(Equal(Event{Type: WatchFailed, Err: errors.New("oh no")})))
+	})
+
+	It("should default a non-positive interval", func() {
+		defaulted := NewWatcher(fakeGordon, map[string]string{"owner": "executor"}, 0)
+		defaulted.Stop()
+
+		Eventually(defaulted.Events).Should(BeClosed())
+	})
+
+	It("should close the events channel when stopped", func() {
+		watcher.Stop()
+		Eventually(watcher.Events).Should(BeClosed())
+	})
+})